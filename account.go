@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"task-scheduler/pkg/logger"
+	"task-scheduler/pkg/mail"
+)
+
+const (
+	// 一次性令牌用途
+	tokenPurposeVerifyEmail   = "verify_email"
+	tokenPurposeResetPassword = "reset_password"
+
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+
+	// 同一来源 IP 连续登录失败达到次数后，锁定该账号在这个来源的登录一段时间
+	maxFailedLogins      = 5
+	loginLockoutDuration = 15 * time.Minute
+	// 所有来源合计失败达到次数后，锁定账号在未知来源的登录，防止轮换来源 IP 猜测密码；
+	// 近期登录成功过的来源不受影响，避免他人借此把账号所有者锁在外面
+	maxAccountFailedLogins = 20
+	trustedSourceTTL       = 30 * 24 * time.Hour
+	// 超过该时间没有新的失败且未处于锁定期的记录由 pruneLoginFailures 清理
+	loginFailureTTL      = 24 * time.Hour
+	loginFailureInterval = time.Hour
+	// accountLoginSource 记录账号在所有来源合计失败次数的行所使用的来源
+	accountLoginSource = ""
+
+	mailSendTimeout = 30 * time.Second
+)
+
+// mailSender 账号相关邮件的发送器，在 main 中根据配置初始化
+var mailSender mail.Sender = &mail.OutboxSender{Dir: "outbox", From: "no-reply@localhost"}
+
+// GetUserByEmail 根据邮箱获取用户
+func GetUserByEmail(email string) (*User, error) {
+	var user User
+	err := db.QueryRow(
+		"SELECT id, username, email, email_verified_at, disabled_at FROM users WHERE email = ?",
+		email,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerifiedAt, &user.DisabledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// createUserToken 生成一次性令牌，同一用途之前未使用的令牌随之失效
+func createUserToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(
+		"UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		now, userID, purpose,
+	); err != nil {
+		return "", err
+	}
+	if _, err := db.Exec(
+		"INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		userID, purpose, hashToken(raw), now.Add(ttl),
+	); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken 校验并作废一次性令牌，返回令牌所属用户
+func consumeUserToken(raw, purpose string) (int, error) {
+	hash := hashToken(raw)
+	now := time.Now()
+	// 单条 UPDATE 保证并发请求中只有一个能使用成功
+	result, err := db.Exec(
+		"UPDATE user_tokens SET used_at = ? WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		now, hash, purpose, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, errInvalidToken
+	}
+
+	var userID int
+	err = db.QueryRow("SELECT user_id FROM user_tokens WHERE token_hash = ?", hash).Scan(&userID)
+	return userID, err
+}
+
+// MarkEmailVerified 标记用户邮箱已验证
+func MarkEmailVerified(userID int) error {
+	_, err := db.Exec("UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL", time.Now(), userID)
+	return err
+}
+
+// ClearEmailVerification 清除邮箱验证状态（邮箱变更后需要重新验证）
+func ClearEmailVerification(userID int) error {
+	_, err := db.Exec("UPDATE users SET email_verified_at = NULL WHERE id = ?", userID)
+	return err
+}
+
+// SetUserPassword 修改密码哈希，并解除登录失败锁定
+func SetUserPassword(userID int, passwordHash string) error {
+	if _, err := db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, userID); err != nil {
+		return err
+	}
+	return UnlockUser(userID)
+}
+
+// SetUserDisabled 停用或启用账号
+func SetUserDisabled(userID int, disabled bool) error {
+	var disabledAt interface{}
+	if disabled {
+		disabledAt = time.Now()
+	}
+	_, err := db.Exec("UPDATE users SET disabled_at = ? WHERE id = ?", disabledAt, userID)
+	return err
+}
+
+// UnlockUser 解除账号在所有来源的登录失败锁定
+func UnlockUser(userID int) error {
+	_, err := db.Exec("DELETE FROM login_failures WHERE user_id = ?", userID)
+	return err
+}
+
+// loginLocked 判断账号在该来源 IP 的登录是否处于锁定期：该来源自身被锁定，
+// 或账号在所有来源合计被锁定且该来源近期没有登录成功过
+func loginLocked(userID int, ip string, now time.Time) (bool, error) {
+	rows, err := db.Query(
+		"SELECT source_ip, locked_until, last_success_at FROM login_failures WHERE user_id = ? AND source_ip IN (?, ?)",
+		userID, ip, accountLoginSource,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var sourceLocked, accountLocked, trusted bool
+	for rows.Next() {
+		var source string
+		var lockedUntil, lastSuccess sql.NullTime
+		if err := rows.Scan(&source, &lockedUntil, &lastSuccess); err != nil {
+			return false, err
+		}
+		locked := lockedUntil.Valid && now.Before(lockedUntil.Time)
+		if source == accountLoginSource {
+			accountLocked = locked
+		} else {
+			sourceLocked = locked
+			trusted = lastSuccess.Valid && now.Sub(lastSuccess.Time) < trustedSourceTTL
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return sourceLocked || (accountLocked && !trusted), nil
+}
+
+// recordFailedLogin 累计账号在该来源 IP 和所有来源合计的登录失败次数，达到各自的上限时锁定并重新计数
+func recordFailedLogin(userID int, ip string) error {
+	if err := countFailedLogin(userID, ip, maxFailedLogins); err != nil {
+		return err
+	}
+	return countFailedLogin(userID, accountLoginSource, maxAccountFailedLogins)
+}
+
+// countFailedLogin 累计一行登录失败记录，达到 limit 时锁定 loginLockoutDuration 并重新计数
+func countFailedLogin(userID int, source string, limit int) error {
+	now := time.Now()
+	// locked_until 在 failed_count 之前赋值，引用的是 failed_count 的旧值
+	update := func() (int64, error) {
+		result, err := db.Exec(
+			`UPDATE login_failures SET
+				locked_until = CASE WHEN failed_count + 1 >= ? THEN ? ELSE locked_until END,
+				failed_count = CASE WHEN failed_count + 1 >= ? THEN 0 ELSE failed_count + 1 END,
+				last_failed_at = ?
+			WHERE user_id = ? AND source_ip = ?`,
+			limit, now.Add(loginLockoutDuration), limit, now, userID, source,
+		)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	affected, err := update()
+	if err != nil || affected > 0 {
+		return err
+	}
+	_, err = db.Exec(
+		"INSERT INTO login_failures (user_id, source_ip, failed_count, last_failed_at) VALUES (?, ?, 1, ?)",
+		userID, source, now,
+	)
+	// 并发的失败登录已插入该行时改为累加
+	if errors.Is(translateDuplicate(err), errDuplicate) {
+		_, err = update()
+	}
+	return err
+}
+
+// resetFailedLogins 登录成功后清空该来源的失败计数，并记下该来源为近期登录成功过的来源
+func resetFailedLogins(userID int, ip string) error {
+	_, err := db.Exec(
+		`INSERT INTO login_failures (user_id, source_ip, failed_count, last_success_at) VALUES (?, ?, 0, ?)
+		ON DUPLICATE KEY UPDATE failed_count = 0, locked_until = NULL, last_success_at = VALUES(last_success_at)`,
+		userID, ip, time.Now(),
+	)
+	return err
+}
+
+// pruneLoginFailures 删除已过期的登录失败记录：不在锁定期、近期没有失败，且不是近期登录成功过的来源
+func pruneLoginFailures() {
+	now := time.Now()
+	result, err := db.Exec(
+		`DELETE FROM login_failures
+		WHERE (locked_until IS NULL OR locked_until < ?)
+			AND (last_failed_at IS NULL OR last_failed_at < ?)
+			AND (last_success_at IS NULL OR last_success_at < ?)`,
+		now, now.Add(-loginFailureTTL), now.Add(-trustedSourceTTL),
+	)
+	if err != nil {
+		logger.Errorf("Failed to prune login failures: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		logger.Infof("Pruned %d expired login failure records", n)
+	}
+}
+
+// appBaseURL 返回邮件链接使用的前端地址
+func appBaseURL() string {
+	if v := os.Getenv("APP_BASE_URL"); v != "" {
+		return v
+	}
+	return "http://localhost:8080"
+}
+
+// sendAccountEmail 生成一次性令牌并在后台发送邮件，发送失败只记录日志
+func sendAccountEmail(user *User, purpose string, ttl time.Duration, subject, path, text string) error {
+	raw, err := createUserToken(user.ID, purpose, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + path + "?token=" + url.QueryEscape(raw)
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n\n%s\n\nThis link expires at %s.\n",
+			user.Username, text, link, time.Now().Add(ttl).UTC().Format(time.RFC1123)),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := mailSender.Send(ctx, msg); err != nil {
+			logger.Errorf("Failed to send %s email to user %d: %v", purpose, user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendVerificationEmail 发送邮箱验证邮件
+func sendVerificationEmail(user *User) error {
+	return sendAccountEmail(user, tokenPurposeVerifyEmail, emailVerificationTTL,
+		"Verify your email address", "/verify-email",
+		"Please confirm your email address by opening the link below:")
+}
+
+// sendPasswordResetEmail 发送密码重置邮件
+func sendPasswordResetEmail(user *User) error {
+	return sendAccountEmail(user, tokenPurposeResetPassword, passwordResetTTL,
+		"Reset your password", "/reset-password",
+		"We received a request to reset your password. If it was you, open the link below; otherwise ignore this email:")
+}
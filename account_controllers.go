@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+
+	"task-scheduler/pkg/logger"
+)
+
+// tokenRequest 邮箱验证请求体
+type tokenRequest struct {
+	Token string `json:"token"`
+}
+
+// forgotPasswordRequest 忘记密码请求体
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// resetPasswordRequest 重置密码请求体
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// verifyEmailHandler 使用邮件中的令牌验证邮箱
+func verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	userID, err := consumeUserToken(req.Token, tokenPurposeVerifyEmail)
+	if err != nil {
+		if err == errInvalidToken {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired token")
+		} else {
+			logger.Errorf("Error verifying email: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to verify email")
+		}
+		return
+	}
+
+	if err := MarkEmailVerified(userID); err != nil {
+		logger.Errorf("Error marking email verified: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendVerificationHandler 重新发送当前用户的邮箱验证邮件
+func resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user.EmailVerifiedAt != nil {
+		sendErrorResponse(w, http.StatusConflict, "Email is already verified")
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		logger.Errorf("Error sending verification email: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// forgotPasswordHandler 发送密码重置邮件；无论邮箱是否存在都返回相同响应，避免枚举账号
+func forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	user, err := GetUserByEmail(req.Email)
+	switch {
+	// 只向已验证的邮箱发送重置链接，未验证的地址不一定属于账号所有者
+	case err == nil && user.DisabledAt == nil && user.EmailVerifiedAt != nil:
+		if err := sendPasswordResetEmail(user); err != nil {
+			logger.Errorf("Error sending password reset email: %v", err)
+		}
+	case err != nil && !errors.Is(err, errUserNotFound):
+		logger.Errorf("Error getting user for password reset: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPasswordHandler 使用重置令牌设置新密码，并吊销该用户已签发的令牌
+func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding reset password request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Errorf("Error hashing password: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	userID, err := consumeUserToken(req.Token, tokenPurposeResetPassword)
+	if err != nil {
+		if err == errInvalidToken {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired token")
+		} else {
+			logger.Errorf("Error consuming reset token: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to reset password")
+		}
+		return
+	}
+
+	if err := SetUserPassword(userID, string(hashedPassword)); err != nil {
+		logger.Errorf("Error resetting password: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	if err := revokeUserTokens(userID); err != nil {
+		logger.Errorf("Error revoking user tokens: %v", err)
+	}
+	// 能收到重置邮件说明邮箱可用
+	if err := MarkEmailVerified(userID); err != nil {
+		logger.Errorf("Error marking email verified: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateAccountStatus 修改账号状态并返回修改后的用户，供停用、启用、解锁接口共用
+func updateAccountStatus(w http.ResponseWriter, r *http.Request, update func(id int) error) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// 检查用户是否存在
+	user, err := GetUserByID(r.Context(), id)
+	if err != nil {
+		logger.Errorf("Error checking user existence: %v", err)
+		sendError(w, err, "Failed to check user existence")
+		return
+	}
+
+	if err := update(id); err != nil {
+		logger.Errorf("Error updating account status: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update account status")
+		return
+	}
+
+	resultUser, err := GetUserByID(r.Context(), id)
+	if err != nil {
+		logger.Errorf("Error getting updated user: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get updated user")
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceUser, id, user, resultUser)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resultUser)
+}
+
+// disableUserHandler 停用账号并吊销其令牌
+func disableUserHandler(w http.ResponseWriter, r *http.Request) {
+	if id, _ := strconv.Atoi(mux.Vars(r)["id"]); id == currentUser(r).ID {
+		sendErrorResponse(w, http.StatusConflict, "You cannot disable your own account")
+		return
+	}
+	updateAccountStatus(w, r, func(id int) error {
+		if err := SetUserDisabled(id, true); err != nil {
+			return err
+		}
+		return revokeUserTokens(id)
+	})
+}
+
+// enableUserHandler 重新启用账号
+func enableUserHandler(w http.ResponseWriter, r *http.Request) {
+	updateAccountStatus(w, r, func(id int) error {
+		return SetUserDisabled(id, false)
+	})
+}
+
+// unlockUserHandler 解除登录失败锁定
+func unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	updateAccountStatus(w, r, UnlockUser)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"task-scheduler/pkg/logger"
+)
+
+// apiKeyRequest 创建/更新 API 密钥的请求体
+type apiKeyRequest struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// apiKeyCreatedResponse 创建密钥的响应，明文密钥只在此处返回一次
+type apiKeyCreatedResponse struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// validate 校验请求数据
+func (req *apiKeyRequest) validate() string {
+	if req.Name == "" || len(req.Name) > 100 {
+		return "Name is required and must be at most 100 characters"
+	}
+	if len(req.Scopes) == 0 {
+		return "At least one scope is required"
+	}
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			return "Unknown scope: " + scope
+		}
+	}
+	for _, entry := range req.AllowedIPs {
+		if !validIPEntry(entry) {
+			return "Invalid IP or CIDR: " + entry
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return "expires_at must be in the future"
+	}
+	return ""
+}
+
+// createAPIKeyHandler 为当前用户创建 API 密钥
+func createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Errorf("Error decoding api key: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if msg := req.validate(); msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	key := &APIKey{
+		UserID:     currentUser(r).ID,
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	raw, err := IssueAPIKey(key)
+	if err != nil {
+		logger.Errorf("Error creating api key: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create api key")
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceAPIKey, key.ID, nil, key)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyCreatedResponse{APIKey: key, Key: raw})
+}
+
+// getAPIKeysHandler 获取当前用户的 API 密钥
+func getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := GetAPIKeysByUserID(currentUser(r).ID)
+	if err != nil {
+		logger.Errorf("Error getting api keys: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get api keys")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+// updateAPIKeyHandler 修改 API 密钥的名称、作用域、IP 白名单或过期时间
+func updateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid api key ID")
+		return
+	}
+
+	// 检查密钥是否存在
+	key, err := GetAPIKeyByID(currentUser(r).ID, id)
+	if err != nil {
+		logger.Errorf("Error checking api key existence: %v", err)
+		sendError(w, err, "Failed to check api key existence")
+		return
+	}
+	if key.RevokedAt != nil {
+		sendErrorResponse(w, http.StatusConflict, "API key has been revoked")
+		return
+	}
+
+	var req apiKeyRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Errorf("Error decoding api key update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if msg := req.validate(); msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	before := *key
+	key.Name = req.Name
+	key.Scopes = req.Scopes
+	key.AllowedIPs = req.AllowedIPs
+	key.ExpiresAt = req.ExpiresAt
+	err = UpdateAPIKey(key)
+	if err != nil {
+		logger.Errorf("Error updating api key: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update api key")
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceAPIKey, key.ID, &before, key)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(key)
+}
+
+// revokeAPIKeyHandler 吊销 API 密钥
+func revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid api key ID")
+		return
+	}
+
+	userID := currentUser(r).ID
+	key, err := GetAPIKeyByID(userID, id)
+	if err != nil {
+		logger.Errorf("Error checking api key existence: %v", err)
+		sendError(w, err, "Failed to check api key existence")
+		return
+	}
+
+	err = RevokeAPIKey(userID, id)
+	if err != nil {
+		logger.Errorf("Error revoking api key: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to revoke api key")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceAPIKey, key.ID, key, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
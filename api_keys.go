@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	apiKeyPrefix = "tfk_"
+
+	// apiKeyAttempts 生成的前缀与已有密钥冲突时最多尝试的次数
+	apiKeyAttempts = 3
+
+	// API 密钥作用域
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+// APIKey 个人 API 密钥模型定义
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 用于识别密钥，明文保存
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"` // IP 或 CIDR，空表示不限制
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope 判断密钥是否拥有指定作用域，write 隐含 read
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || (s == scopeWrite && scope == scopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsIP 判断请求来源是否在密钥的 IP 白名单中
+func (k *APIKey) AllowsIP(remote string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range k.AllowedIPs {
+		if _, cidr, err := net.ParseCIDR(allowed); err == nil {
+			if cidr.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validScope 判断作用域名称是否合法
+func validScope(scope string) bool {
+	return scope == scopeRead || scope == scopeWrite
+}
+
+// validIPEntry 判断白名单条目是否为合法的 IP 或 CIDR
+func validIPEntry(entry string) bool {
+	if _, _, err := net.ParseCIDR(entry); err == nil {
+		return true
+	}
+	return net.ParseIP(entry) != nil
+}
+
+// generateAPIKey 生成新的明文密钥，返回 (完整密钥, 前缀)
+func generateAPIKey() (string, string, error) {
+	prefix, err := randomToken(8)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := randomToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	return apiKeyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+// IssueAPIKey 生成新密钥并保存，前缀与已有密钥冲突时重新生成；返回只展示一次的完整密钥
+func IssueAPIKey(key *APIKey) (string, error) {
+	for attempt := 1; ; attempt++ {
+		raw, prefix, err := generateAPIKey()
+		if err != nil {
+			return "", err
+		}
+		key.Prefix = prefix
+		key.KeyHash = hashToken(raw)
+
+		err = CreateAPIKey(key)
+		if err == nil {
+			return raw, nil
+		}
+		if !errors.Is(err, errDuplicate) || attempt == apiKeyAttempts {
+			return "", err
+		}
+	}
+}
+
+// CreateAPIKey 创建 API 密钥
+func CreateAPIKey(key *APIKey) error {
+	result, err := db.Exec(
+		"INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, allowed_ips, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		key.UserID, key.Name, key.Prefix, key.KeyHash,
+		strings.Join(key.Scopes, ","), strings.Join(key.AllowedIPs, ","), key.ExpiresAt,
+	)
+	if err != nil {
+		return translateDuplicate(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	key.ID = int(id)
+	key.CreatedAt = time.Now()
+	return nil
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, allowed_ips, expires_at, last_used_at, revoked_at, created_at"
+
+// scanAPIKey 从查询结果中读取 API 密钥
+func scanAPIKey(scanner interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var key APIKey
+	var scopes, allowedIPs string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := scanner.Scan(
+		&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash,
+		&scopes, &allowedIPs, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = splitList(scopes)
+	key.AllowedIPs = splitList(allowedIPs)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// GetAPIKeysByUserID 获取用户的所有 API 密钥
+func GetAPIKeysByUserID(userID int) ([]APIKey, error) {
+	rows, err := db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, nil
+}
+
+// GetAPIKeyByID 根据ID获取用户的 API 密钥
+func GetAPIKeyByID(userID, id int) (*APIKey, error) {
+	key, err := scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ? AND user_id = ?", id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// UpdateAPIKey 更新密钥的名称、作用域、IP 白名单和过期时间
+func UpdateAPIKey(key *APIKey) error {
+	_, err := db.Exec(
+		"UPDATE api_keys SET name = ?, scopes = ?, allowed_ips = ?, expires_at = ? WHERE id = ? AND user_id = ?",
+		key.Name, strings.Join(key.Scopes, ","), strings.Join(key.AllowedIPs, ","), key.ExpiresAt,
+		key.ID, key.UserID,
+	)
+	return err
+}
+
+// RevokeAPIKey 吊销 API 密钥
+func RevokeAPIKey(userID, id int) error {
+	_, err := db.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL", time.Now(), id, userID)
+	return err
+}
+
+// authenticateAPIKey 校验明文 API 密钥，返回对应的密钥记录
+func authenticateAPIKey(raw string) (*APIKey, error) {
+	rest := strings.TrimPrefix(raw, apiKeyPrefix)
+	sep := strings.IndexByte(rest, '_')
+	if sep <= 0 {
+		return nil, errInvalidToken
+	}
+
+	key, err := scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", rest[:sep]))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidToken
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(raw)), []byte(key.KeyHash)) != 1 {
+		return nil, errInvalidToken
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, errInvalidToken
+	}
+
+	// 最近使用时间精确到分钟即可，避免每个请求都写库
+	now := time.Now()
+	if _, err := db.Exec(
+		"UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+		now, key.ID, now.Add(-time.Minute),
+	); err != nil {
+		logger.Warnf("Failed to update last_used_at of api key %d: %v", key.ID, err)
+	}
+
+	return key, nil
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
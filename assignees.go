@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"task-scheduler/pkg/validation"
+)
+
+const (
+	maxTaskAssignees = 20
+	maxTaskWatchers  = 50
+)
+
+// 用户与任务的关系，用于按关系筛选任务
+const (
+	taskRoleOwner    = "owner"    // 任务所在列表的所有者（user_id）
+	taskRoleCreated  = "created"  // 创建者
+	taskRoleAssigned = "assigned" // 负责人之一
+	taskRoleWatching = "watching" // 关注者之一
+)
+
+// containsID 判断 id 是否在列表中
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccessTask 判断用户能否查看任务：所有者、负责人、关注者或拥有权限的用户
+func canAccessTask(user *User, task *Task) bool {
+	if canAccessUser(user, task.UserID, permManageAllTodos) {
+		return true
+	}
+	return user != nil && (containsID(task.AssigneeIDs, user.ID) || containsID(task.WatcherIDs, user.ID))
+}
+
+// canEditTask 判断用户能否修改任务：所有者、负责人或拥有权限的用户，关注者只能查看
+func canEditTask(user *User, task *Task) bool {
+	if canAccessUser(user, task.UserID, permManageAllTodos) {
+		return true
+	}
+	return user != nil && containsID(task.AssigneeIDs, user.ID)
+}
+
+// canManageTask 判断用户能否管理任务的人员（所有者、负责人、关注者）及他人上传的附件：只有所有者或拥有权限的用户
+func canManageTask(user *User, task *Task) bool {
+	return canAccessUser(user, task.UserID, permManageAllTodos)
+}
+
+// applyTaskRole 将按关系筛选的条件加入过滤条件，role 为空时按所有者筛选
+func applyTaskRole(filter *TaskFilter, role string, userID int) bool {
+	switch role {
+	case "", taskRoleOwner:
+		filter.UserID = userID
+	case taskRoleCreated:
+		filter.CreatedBy = userID
+	case taskRoleAssigned:
+		filter.AssigneeID = userID
+	case taskRoleWatching:
+		filter.WatcherID = userID
+	default:
+		return false
+	}
+	return true
+}
+
+// dedupeIDs 去除重复的ID，保持原有顺序
+func dedupeIDs(ids []int) []int {
+	seen := make(map[int]bool)
+	result := []int{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// sameIDs 判断两个ID列表是否包含相同的ID，不考虑顺序和重复
+func sameIDs(a, b []int) bool {
+	a, b = dedupeIDs(a), dedupeIDs(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for _, id := range a {
+		if !containsID(b, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTaskPeople 校验负责人和关注者都是存在的用户，并去除重复的ID
+func checkTaskPeople(task *Task) error {
+	var v validation.Validator
+	task.AssigneeIDs = dedupeIDs(task.AssigneeIDs)
+	task.WatcherIDs = dedupeIDs(task.WatcherIDs)
+	for _, f := range []struct {
+		field string
+		ids   []int
+		max   int
+	}{
+		{"assignee_ids", task.AssigneeIDs, maxTaskAssignees},
+		{"watcher_ids", task.WatcherIDs, maxTaskWatchers},
+	} {
+		if len(f.ids) > f.max {
+			v.Add(f.field, validation.CodeTooLong, fmt.Sprintf("must contain at most %d users", f.max))
+			continue
+		}
+		if len(f.ids) == 0 {
+			continue
+		}
+		args := make([]interface{}, len(f.ids))
+		for i, id := range f.ids {
+			args[i] = id
+		}
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM users WHERE id IN ("+placeholders(len(f.ids))+")", args...).Scan(&count)
+		if err != nil {
+			return err
+		}
+		v.Check(count == len(f.ids), f.field, validation.CodeNotAllowed, "must be existing users")
+	}
+	return v.Err()
+}
+
+// syncTaskPeople 在调用方的事务中将任务的负责人和关注者更新为 task.AssigneeIDs 和 task.WatcherIDs
+func syncTaskPeople(tx *sql.Tx, task *Task) error {
+	if task.AssigneeIDs == nil {
+		task.AssigneeIDs = []int{}
+	}
+	if task.WatcherIDs == nil {
+		task.WatcherIDs = []int{}
+	}
+	if err := syncTaskUsers(tx, "task_assignees", task.ID, task.AssigneeIDs); err != nil {
+		return err
+	}
+	return syncTaskUsers(tx, "task_watchers", task.ID, task.WatcherIDs)
+}
+
+// syncTaskUsers 将任务在关联表中的用户更新为 userIDs
+func syncTaskUsers(tx *sql.Tx, table string, taskID int, userIDs []int) error {
+	args := []interface{}{taskID}
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	query := "DELETE FROM " + table + " WHERE task_id = ?"
+	if len(userIDs) > 0 {
+		query += " AND user_id NOT IN (" + placeholders(len(userIDs)) + ")"
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, id := range userIDs {
+		if _, err := tx.Exec("INSERT IGNORE INTO "+table+" (task_id, user_id) VALUES (?, ?)", taskID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTaskPeople 为一组任务填充负责人和关注者
+func loadTaskPeople(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	index := make(map[int]*Task, len(tasks))
+	args := make([]interface{}, 0, 2*len(tasks))
+	for i := range tasks {
+		tasks[i].AssigneeIDs = []int{}
+		tasks[i].WatcherIDs = []int{}
+		index[tasks[i].ID] = &tasks[i]
+		args = append(args, tasks[i].ID)
+	}
+	args = append(args, args...)
+
+	in := placeholders(len(tasks))
+	rows, err := db.Query(
+		"SELECT task_id, user_id, TRUE FROM task_assignees WHERE task_id IN ("+in+") UNION ALL "+
+			"SELECT task_id, user_id, FALSE FROM task_watchers WHERE task_id IN ("+in+") ORDER BY user_id",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, userID int
+		var assignee bool
+		if err := rows.Scan(&taskID, &userID, &assignee); err != nil {
+			return err
+		}
+		task, ok := index[taskID]
+		if !ok {
+			continue
+		}
+		if assignee {
+			task.AssigneeIDs = append(task.AssigneeIDs, userID)
+		} else {
+			task.WatcherIDs = append(task.WatcherIDs, userID)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"task-scheduler/pkg/blob"
+	"task-scheduler/pkg/logger"
+)
+
+// multipartOverhead 上传请求体中除文件内容外的边界和头部所占空间的余量
+const multipartOverhead = 64 << 10
+
+// loadAttachment 获取路由中的附件及其任务，并校验当前用户能否访问该任务
+func loadAttachment(w http.ResponseWriter, r *http.Request) (*Attachment, *Task, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid attachment ID")
+		return nil, nil, false
+	}
+
+	attachment, err := GetAttachmentByID(id)
+	if err != nil {
+		logger.Errorf("Error getting attachment: %v", err)
+		sendError(w, err, "Failed to get attachment")
+		return nil, nil, false
+	}
+	task, ok := loadAccessibleTask(w, r, attachment.TaskID)
+	if !ok {
+		return nil, nil, false
+	}
+	return attachment, task, true
+}
+
+// getTaskAttachmentsHandler 获取任务的附件列表
+func getTaskAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadRouteTask(w, r)
+	if !ok {
+		return
+	}
+
+	attachments, err := GetAttachmentsByTaskID(task.ID)
+	if err != nil {
+		logger.Errorf("Error getting attachments: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get attachments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// uploadTaskAttachmentHandler 以 multipart/form-data 上传附件，文件放在 file 字段中；关注者不能上传
+func uploadTaskAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadEditableTask(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, attachmentMaxBytes()+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Request must be multipart/form-data")
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			sendErrorResponse(w, http.StatusBadRequest, "Missing file field")
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			sendError(w, errAttachmentTooLarge, "")
+			return
+		}
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid multipart body")
+			return
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		caller := currentUser(r)
+		attachment := &Attachment{TaskID: task.ID, UploaderID: &caller.ID, Filename: part.FileName()}
+		err = SaveAttachment(r.Context(), attachment, part.Header.Get("Content-Type"), part)
+		part.Close()
+		if errors.As(err, &tooLarge) {
+			err = errAttachmentTooLarge
+		}
+		if err != nil {
+			logger.Errorf("Error saving attachment: %v", err)
+			sendError(w, err, "Failed to save attachment")
+			return
+		}
+		recordAudit(r, auditActionCreate, auditResourceAttachment, attachment.ID, nil, attachment)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(attachment)
+		return
+	}
+}
+
+// getAttachmentHandler 获取附件的元数据
+func getAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, _, ok := loadAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// downloadAttachmentHandler 下载附件内容，以校验和作为 ETag
+func downloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, _, ok := loadAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	etag := `"` + attachment.Checksum + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	content, err := blobStore.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		logger.Errorf("Error reading attachment %d: %v", attachment.ID, err)
+		if errors.Is(err, blob.ErrNotFound) {
+			sendError(w, errAttachmentNotFound, "")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to read attachment")
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		logger.Errorf("Error sending attachment %d: %v", attachment.ID, err)
+	}
+}
+
+// deleteAttachmentHandler 删除附件，仍能修改任务的上传者、任务所有者或拥有权限的用户可以删除
+func deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, task, ok := loadAttachment(w, r)
+	if !ok {
+		return
+	}
+	caller := currentUser(r)
+	isUploader := attachment.UploaderID != nil && *attachment.UploaderID == caller.ID
+	if !canManageTask(caller, task) && !(isUploader && canEditTask(caller, task)) {
+		sendForbidden(w)
+		return
+	}
+
+	if err := DeleteAttachment(attachment); err != nil {
+		logger.Errorf("Error deleting attachment: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete attachment")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceAttachment, attachment.ID, attachment, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"task-scheduler/pkg/blob"
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	defaultAttachmentMaxBytes = 10 << 20
+	maxTaskAttachments        = 50
+	maxAttachmentNameLength   = 255
+
+	// attachmentCleanupInterval 清理已删除任务遗留附件的间隔
+	attachmentCleanupInterval = 10 * time.Minute
+	attachmentCleanupBatch    = 100
+	blobOperationTimeout      = 5 * time.Minute
+)
+
+// attachmentSniffTypes 允许上传的类型，值为按内容探测（http.DetectContentType）时可以接受的结果；
+// CSV 和纯文本无法从内容上区分，都探测为 text/plain
+var attachmentSniffTypes = map[string][]string{
+	"image/png":       {"image/png"},
+	"image/jpeg":      {"image/jpeg"},
+	"image/gif":       {"image/gif"},
+	"image/webp":      {"image/webp"},
+	"application/pdf": {"application/pdf"},
+	"text/plain":      {"text/plain"},
+	"text/csv":        {"text/plain"},
+}
+
+// blobStore 附件内容的存储，在 main 中根据配置初始化
+var blobStore blob.Store = &blob.FSStore{Dir: "attachments"}
+
+// Attachment 任务附件的元数据，内容保存在 blobStore 中
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	UploaderID  *int      `json:"uploader_id"` // 上传者账号删除后为空
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"` // 内容的 SHA-256，十六进制
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+var (
+	errAttachmentNotFound     = &appError{Kind: errNotFound, Code: "attachment_not_found", Message: "Attachment not found"}
+	errAttachmentTooLarge     = &appError{Kind: errTooLarge, Code: "attachment_too_large", Message: "Attachment exceeds the size limit"}
+	errAttachmentEmpty        = &appError{Kind: errValidation, Code: "attachment_empty", Message: "Attachment is empty"}
+	errAttachmentType         = &appError{Kind: errUnsupported, Code: "attachment_type_not_allowed", Message: "Attachment type is not allowed"}
+	errAttachmentTypeMismatch = &appError{Kind: errUnsupported, Code: "attachment_type_mismatch", Message: "Attachment content does not match its type"}
+	errTooManyAttachments     = &appError{Kind: errConflict, Code: "too_many_attachments", Message: fmt.Sprintf("A task can have at most %d attachments", maxTaskAttachments)}
+)
+
+const attachmentColumns = "id, task_id, uploader_id, filename, content_type, size, checksum, storage_key, created_at"
+
+// attachmentMaxBytes 单个附件的大小上限，可通过 ATTACHMENT_MAX_BYTES 配置
+func attachmentMaxBytes() int64 {
+	if v := os.Getenv("ATTACHMENT_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+		logger.Warnf("Ignoring invalid ATTACHMENT_MAX_BYTES %q", v)
+	}
+	return defaultAttachmentMaxBytes
+}
+
+// scanAttachment 从查询结果中读取附件
+func scanAttachment(scanner interface{ Scan(...interface{}) error }) (*Attachment, error) {
+	var a Attachment
+	err := scanner.Scan(&a.ID, &a.TaskID, &a.UploaderID, &a.Filename, &a.ContentType, &a.Size, &a.Checksum, &a.StorageKey, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// cleanAttachmentName 只保留文件名部分并去掉控制字符，避免路径和响应头注入
+func cleanAttachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxAttachmentNameLength {
+		name = string(runes[len(runes)-maxAttachmentNameLength:])
+	}
+	return name
+}
+
+// attachmentContentType 根据声明的类型（缺省时按扩展名推断）和内容探测结果确定附件类型
+func attachmentContentType(declared, filename string, head []byte) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))))
+	}
+	accepted, ok := attachmentSniffTypes[mediaType]
+	if !ok {
+		return "", errAttachmentType
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	for _, t := range accepted {
+		if t == sniffed {
+			return mediaType, nil
+		}
+	}
+	return "", errAttachmentTypeMismatch
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// SaveAttachment 校验类型后将内容写入 blobStore 并保存元数据；内容边写边计算校验和，超过大小上限时丢弃
+func SaveAttachment(ctx context.Context, a *Attachment, declaredType string, r io.Reader) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM task_attachments WHERE task_id = ?", a.TaskID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= maxTaskAttachments {
+		return errTooManyAttachments
+	}
+
+	a.Filename = cleanAttachmentName(a.Filename)
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(head) == 0 {
+		return errAttachmentEmpty
+	}
+	a.ContentType, err = attachmentContentType(declaredType, a.Filename, head)
+	if err != nil {
+		return err
+	}
+
+	token, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+	a.StorageKey = fmt.Sprintf("tasks/%d/%s", a.TaskID, token)
+
+	maxBytes := attachmentMaxBytes()
+	hash := sha256.New()
+	counter := &countingReader{r: io.LimitReader(br, maxBytes+1)}
+	if err := blobStore.Put(ctx, a.StorageKey, io.TeeReader(counter, hash), -1, a.ContentType); err != nil {
+		deleteBlob(a.StorageKey)
+		return err
+	}
+	if counter.n > maxBytes {
+		deleteBlob(a.StorageKey)
+		return errAttachmentTooLarge
+	}
+	a.Size = counter.n
+	a.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	a.CreatedAt = time.Now()
+	result, err := db.Exec(
+		"INSERT INTO task_attachments (task_id, uploader_id, filename, content_type, size, checksum, storage_key, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		a.TaskID, a.UploaderID, a.Filename, a.ContentType, a.Size, a.Checksum, a.StorageKey, a.CreatedAt,
+	)
+	if err != nil {
+		deleteBlob(a.StorageKey)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+	return nil
+}
+
+// GetAttachmentByID 根据ID获取附件；所属任务已删除、等待清理的附件视为不存在
+func GetAttachmentByID(id int) (*Attachment, error) {
+	a, err := scanAttachment(db.QueryRow("SELECT "+attachmentColumns+" FROM task_attachments WHERE id = ? AND task_id IS NOT NULL", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errAttachmentNotFound
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetAttachmentsByTaskID 获取任务的附件，按上传时间排序
+func GetAttachmentsByTaskID(taskID int) ([]Attachment, error) {
+	rows, err := db.Query("SELECT "+attachmentColumns+" FROM task_attachments WHERE task_id = ? ORDER BY created_at, id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment 删除附件：先与任务解除关联，内容删除失败时由 cleanupOrphanAttachments 重试
+func DeleteAttachment(a *Attachment) error {
+	if _, err := db.Exec("UPDATE task_attachments SET task_id = NULL WHERE id = ?", a.ID); err != nil {
+		return err
+	}
+	if deleteBlob(a.StorageKey) {
+		_, err := db.Exec("DELETE FROM task_attachments WHERE id = ?", a.ID)
+		return err
+	}
+	return nil
+}
+
+// deleteBlob 删除附件内容，失败只记录日志
+func deleteBlob(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), blobOperationTimeout)
+	defer cancel()
+	if err := blobStore.Delete(ctx, key); err != nil {
+		logger.Errorf("Failed to delete blob %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// attachmentCleanup 保证同一时刻只有一次清理在运行；运行中再次触发时，当前清理结束后再补跑一轮
+var attachmentCleanup struct {
+	sync.Mutex
+	running bool
+	again   bool
+}
+
+// cleanupOrphanAttachments 删除所属任务已删除的附件内容。任务删除（包括子任务、项目和用户的级联删除）时
+// 外键将 task_id 置空，内容在这里统一清理，数据库删除不依赖对象存储是否可用。
+// 定时任务和任务删除后都会触发，并发触发时合并为一次补跑
+func cleanupOrphanAttachments() {
+	attachmentCleanup.Lock()
+	if attachmentCleanup.running {
+		attachmentCleanup.again = true
+		attachmentCleanup.Unlock()
+		return
+	}
+	attachmentCleanup.running = true
+	attachmentCleanup.Unlock()
+
+	for {
+		cleanupOrphanAttachmentBatch()
+
+		attachmentCleanup.Lock()
+		if !attachmentCleanup.again {
+			attachmentCleanup.running = false
+			attachmentCleanup.Unlock()
+			return
+		}
+		attachmentCleanup.again = false
+		attachmentCleanup.Unlock()
+	}
+}
+
+// cleanupOrphanAttachmentBatch 清理一批遗留附件：从未尝试过的优先，其余按上次尝试时间先后，
+// 删除失败的记录尝试时间，避免一直失败的内容占满每一批
+func cleanupOrphanAttachmentBatch() {
+	rows, err := db.Query(
+		"SELECT id, storage_key FROM task_attachments WHERE task_id IS NULL ORDER BY cleanup_attempted_at, id LIMIT ?",
+		attachmentCleanupBatch,
+	)
+	if err != nil {
+		logger.Errorf("Failed to query orphan attachments: %v", err)
+		return
+	}
+	type orphan struct {
+		id  int
+		key string
+	}
+	var orphans []orphan
+	for rows.Next() {
+		var o orphan
+		if err := rows.Scan(&o.id, &o.key); err != nil {
+			rows.Close()
+			logger.Errorf("Failed to scan orphan attachment: %v", err)
+			return
+		}
+		orphans = append(orphans, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Failed to query orphan attachments: %v", err)
+		return
+	}
+
+	removed := 0
+	for _, o := range orphans {
+		if !deleteBlob(o.key) {
+			if _, err := db.Exec("UPDATE task_attachments SET cleanup_attempted_at = ? WHERE id = ?", time.Now(), o.id); err != nil {
+				logger.Errorf("Failed to record cleanup attempt of attachment %d: %v", o.id, err)
+			}
+			continue
+		}
+		if _, err := db.Exec("DELETE FROM task_attachments WHERE id = ?", o.id); err != nil {
+			logger.Errorf("Failed to delete orphan attachment %d: %v", o.id, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		logger.Infof("Removed %d attachments of deleted tasks", removed)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"task-scheduler/pkg/logger"
+)
+
+// 审计动作
+const (
+	auditActionCreate   = "create"
+	auditActionUpdate   = "update"
+	auditActionDelete   = "delete"
+	auditActionStart    = "start"
+	auditActionStop     = "stop"
+	auditActionRun      = "run"
+	auditActionRollback = "rollback"
+)
+
+// 审计资源类型
+const (
+	auditResourceUser       = "user"
+	auditResourceTask       = "task"
+	auditResourceJob        = "job"
+	auditResourceProject    = "project"
+	auditResourceTag        = "tag"
+	auditResourceComment    = "comment"
+	auditResourceAttachment = "attachment"
+
+	auditResourceWebhook         = "webhook"
+	auditResourceRetentionPolicy = "retention_policy"
+	auditResourceNamespace       = "namespace"
+	auditResourceNamespaceMember = "namespace_member"
+	auditResourceAPIKey          = "api_key"
+	auditResourceSecret          = "secret"
+	auditResourceCalendar        = "calendar"
+)
+
+// auditSystemActor 调度器等后台流程发起的操作使用的操作者名称
+const auditSystemActor = "system"
+
+// AuditEntry 审计日志记录，写入后不再修改
+type AuditEntry struct {
+	ID           int64           `json:"id"`
+	ActorID      *int            `json:"actor_id,omitempty"`
+	ActorName    string          `json:"actor_name"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	Changes      json.RawMessage `json:"changes,omitempty"` // 字段名 -> {before, after}
+	RequestID    string          `json:"request_id"`
+	SourceIP     string          `json:"source_ip"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AuditChange 单个字段的变更
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter 审计日志查询条件，零值表示不限制
+type AuditFilter struct {
+	ActorID      int
+	Action       string
+	ResourceType string
+	ResourceID   string
+	IDs          []interface{} // 只查询这些ID的记录
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+// recordAudit 记录一次变更操作，before/after 为变更前后的资源（创建时 before 为 nil，删除时 after 为 nil）
+// 审计写入失败只记录日志，不影响已完成的操作
+func recordAudit(r *http.Request, action, resourceType string, resourceID interface{}, before, after interface{}) {
+	entry := &AuditEntry{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   fmt.Sprint(resourceID),
+		RequestID:    requestID(r),
+		SourceIP:     sourceIP(r),
+	}
+	if user := currentUser(r); user != nil {
+		entry.ActorID = &user.ID
+		entry.ActorName = user.Username
+	}
+	writeAudit(entry, before, after)
+}
+
+// recordSystemAudit 记录没有请求上下文的操作（如调度器启动时恢复任务），操作者为 system
+func recordSystemAudit(action, resourceType string, resourceID interface{}, before, after interface{}) {
+	writeAudit(&AuditEntry{
+		ActorName:    auditSystemActor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   fmt.Sprint(resourceID),
+	}, before, after)
+}
+
+// writeAudit 填充快照和字段变更后写入审计日志
+func writeAudit(entry *AuditEntry, before, after interface{}) {
+	var err error
+	if entry.Before, err = marshalAuditData(before); err == nil {
+		if entry.After, err = marshalAuditData(after); err == nil {
+			entry.Changes, err = auditDiff(entry.Before, entry.After)
+		}
+	}
+	if err == nil {
+		err = CreateAuditEntry(entry)
+	}
+	if err != nil {
+		logger.Errorf("Failed to record audit entry %s %s/%s: %v", entry.Action, entry.ResourceType, entry.ResourceID, err)
+	}
+}
+
+// marshalAuditData 序列化资源快照，nil 返回空
+func marshalAuditData(v interface{}) (json.RawMessage, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// auditDiff 比较前后快照的顶层字段，返回发生变化的字段
+func auditDiff(before, after json.RawMessage) (json.RawMessage, error) {
+	var b, a map[string]interface{}
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &b); err != nil {
+			return nil, err
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &a); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make(map[string]AuditChange)
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(av, bv) {
+			changes[k] = AuditChange{Before: bv, After: a[k]}
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = AuditChange{After: av}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+// sourceIP 返回请求来源IP
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// nullJSON 空快照写入 NULL
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// CreateAuditEntry 追加一条审计日志
+func CreateAuditEntry(entry *AuditEntry) error {
+	entry.CreatedAt = time.Now()
+	result, err := db.Exec(
+		`INSERT INTO audit_logs (actor_id, actor_name, action, resource_type, resource_id,
+			before_data, after_data, changes, request_id, source_ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ActorID, entry.ActorName, entry.Action, entry.ResourceType, entry.ResourceID,
+		nullJSON(entry.Before), nullJSON(entry.After), nullJSON(entry.Changes),
+		entry.RequestID, entry.SourceIP, entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// EachAuditEntry 按时间倒序遍历符合条件的审计日志
+func EachAuditEntry(filter AuditFilter, fn func(*AuditEntry) error) error {
+	var conds []string
+	var args []interface{}
+	if filter.ActorID != 0 {
+		conds = append(conds, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.ResourceType != "" {
+		conds = append(conds, "resource_type = ?")
+		args = append(args, filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		conds = append(conds, "resource_id = ?")
+		args = append(args, filter.ResourceID)
+	}
+	if len(filter.IDs) > 0 {
+		conds = append(conds, "id IN ("+placeholders(len(filter.IDs))+")")
+		args = append(args, filter.IDs...)
+	}
+	if filter.From != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conds = append(conds, "created_at < ?")
+		args = append(args, *filter.To)
+	}
+
+	query := `SELECT id, actor_id, actor_name, action, resource_type, resource_id,
+		before_data, after_data, changes, request_id, source_ip, created_at FROM audit_logs`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry AuditEntry
+		var actorID sql.NullInt64
+		var before, after, changes []byte
+		err := rows.Scan(
+			&entry.ID, &actorID, &entry.ActorName, &entry.Action, &entry.ResourceType, &entry.ResourceID,
+			&before, &after, &changes, &entry.RequestID, &entry.SourceIP, &entry.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			entry.ActorID = &id
+		}
+		entry.Before, entry.After, entry.Changes = before, after, changes
+
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetAuditEntries 查询审计日志
+func GetAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := EachAuditEntry(filter, func(entry *AuditEntry) error {
+		entries = append(entries, *entry)
+		return nil
+	})
+	return entries, err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// parseAuditFilter 从查询参数解析审计日志过滤条件
+func parseAuditFilter(r *http.Request) (AuditFilter, string) {
+	query := r.URL.Query()
+	filter := AuditFilter{
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+		ResourceID:   query.Get("resource_id"),
+	}
+
+	if v := query.Get("actor_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return filter, "Invalid actor_id"
+		}
+		filter.ActorID = id
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, name + " must be an RFC 3339 timestamp"
+			}
+			*dst = &t
+		}
+	}
+	return filter, ""
+}
+
+// getAuditLogsHandler 分页查询审计日志
+func getAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, msg := parseAuditFilter(r)
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	query := r.URL.Query()
+	filter.Limit = defaultAuditLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			sendErrorResponse(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		filter.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+		filter.Offset = n
+	}
+
+	entries, err := GetAuditEntries(filter)
+	if err != nil {
+		logger.Errorf("Error getting audit logs: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get audit logs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// exportAuditLogsHandler 以 JSON Lines 格式导出符合条件的全部审计日志
+func exportAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, msg := parseAuditFilter(r)
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// 响应头在写出第一条记录时才发送，之后出错只能中断输出
+	enc := json.NewEncoder(w)
+	written := false
+	err := EachAuditEntry(filter, func(entry *AuditEntry) error {
+		if !written {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+			written = true
+		}
+		return enc.Encode(entry)
+	})
+	if err != nil {
+		logger.Errorf("Error exporting audit logs: %v", err)
+		if !written {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to export audit logs")
+		}
+		return
+	}
+	if !written {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errInvalidToken       = errors.New("invalid token")
+)
+
+var (
+	tokenSecret     []byte
+	tokenSecretOnce sync.Once
+)
+
+// getTokenSecret 返回访问令牌的签名密钥，未配置 AUTH_TOKEN_SECRET 时生成进程内随机密钥
+func getTokenSecret() []byte {
+	tokenSecretOnce.Do(func() {
+		if v := os.Getenv("AUTH_TOKEN_SECRET"); v != "" {
+			tokenSecret = []byte(v)
+			return
+		}
+		logger.Warnf("AUTH_TOKEN_SECRET is not set, using a random secret; tokens will not survive restarts")
+		tokenSecret = make([]byte, 32)
+		if _, err := rand.Read(tokenSecret); err != nil {
+			logger.Fatalf("Failed to generate token secret: %v", err)
+		}
+	})
+	return tokenSecret
+}
+
+// accessClaims 访问令牌的声明
+type accessClaims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair 登录和刷新返回的令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// GetUserForLogin 根据用户名或邮箱获取用户（包含密码哈希）
+func GetUserForLogin(login string) (*User, error) {
+	var user User
+	err := db.QueryRow(
+		"SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified_at, disabled_at FROM users WHERE username = ? OR email = ?",
+		login, login,
+	).Scan(
+		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role,
+		&user.CreatedAt, &user.UpdatedAt,
+		&user.EmailVerifiedAt, &user.DisabledAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// issueTokens 为用户签发访问令牌和新的刷新令牌，familyID 为空时开启新的令牌族
+func issueTokens(user *User, familyID string) (*TokenPair, error) {
+	now := time.Now()
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := accessClaims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		},
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(getTokenSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		if familyID, err = randomToken(16); err != nil {
+			return nil, err
+		}
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		user.ID, familyID, hashToken(refresh), now.Add(refreshTokenTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// parseAccessToken 校验访问令牌的签名、有效期和吊销状态
+func parseAccessToken(token string) (*accessClaims, error) {
+	claims := &accessClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return getTokenSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	var revoked int
+	err = db.QueryRow("SELECT COUNT(*) FROM revoked_access_tokens WHERE jti = ?", claims.ID).Scan(&revoked)
+	if err != nil {
+		return nil, err
+	}
+	if revoked > 0 {
+		return nil, errInvalidToken
+	}
+
+	return claims, nil
+}
+
+// rotateRefreshToken 使用刷新令牌换取新令牌，旧令牌立即失效；
+// 已失效的令牌被再次使用时视为泄露，吊销整个令牌族
+func rotateRefreshToken(ctx context.Context, refresh string) (*TokenPair, error) {
+	var (
+		id        int
+		userID    int
+		familyID  string
+		expiresAt time.Time
+		revokedAt sql.NullTime
+	)
+	err := db.QueryRow(
+		"SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?",
+		hashToken(refresh),
+	).Scan(&id, &userID, &familyID, &expiresAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidToken
+		}
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		logger.Warnf("Refresh token reuse detected for user %d, revoking token family", userID)
+		if err := revokeTokenFamily(familyID); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidToken
+	}
+	if time.Now().After(expiresAt) {
+		return nil, errInvalidToken
+	}
+
+	// 条件更新保证并发刷新时只有一个请求成功
+	result, err := db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, errInvalidToken
+	}
+
+	user, err := GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(user, familyID)
+}
+
+// revokeRefreshToken 吊销刷新令牌所在的令牌族
+func revokeRefreshToken(refresh string) error {
+	var familyID string
+	err := db.QueryRow("SELECT family_id FROM refresh_tokens WHERE token_hash = ?", hashToken(refresh)).Scan(&familyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	return revokeTokenFamily(familyID)
+}
+
+// revokeTokenFamily 吊销令牌族中所有未失效的刷新令牌
+func revokeTokenFamily(familyID string) error {
+	_, err := db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", time.Now(), familyID)
+	return err
+}
+
+// revokeUserTokens 吊销用户的所有刷新令牌
+func revokeUserTokens(userID int) error {
+	_, err := db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), userID)
+	return err
+}
+
+// revokeAccessToken 在访问令牌过期前拒绝其使用
+func revokeAccessToken(claims *accessClaims) error {
+	// 顺带清理已过期的吊销记录
+	if _, err := db.Exec("DELETE FROM revoked_access_tokens WHERE expires_at < ?", time.Now()); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"INSERT INTO revoked_access_tokens (jti, expires_at) VALUES (?, ?)",
+		claims.ID, claims.ExpiresAt.Time,
+	)
+	return err
+}
+
+// randomToken 生成 n 字节的随机十六进制字符串
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken 计算令牌的 SHA-256 摘要，数据库中只保存摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
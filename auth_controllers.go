@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"task-scheduler/pkg/logger"
+)
+
+// dummyPasswordHash 用户不存在时也执行一次哈希比较，避免通过响应时间枚举用户名
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// loginRequest 登录请求体，login 可以是用户名或邮箱
+type loginRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// refreshRequest 刷新/注销请求体
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// loginHandler 校验密码并签发令牌
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Errorf("Error decoding login request: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Login == "" || req.Password == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Login and password are required")
+		return
+	}
+
+	user, err := GetUserForLogin(req.Login)
+	if err != nil && !errors.Is(err, errUserNotFound) {
+		logger.Errorf("Error getting user for login: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	// 锁定期间不再校验密码，避免继续猜测；锁定针对当前来源 IP，以及账号合计失败过多时的未知来源
+	ip := sourceIP(r)
+	if user != nil {
+		locked, err := loginLocked(user.ID, ip, time.Now())
+		if err != nil {
+			logger.Errorf("Error checking login lockout: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to log in")
+			return
+		}
+		if locked {
+			sendErrorResponse(w, http.StatusLocked, "Account is temporarily locked, try again later")
+			return
+		}
+	}
+
+	hash := dummyPasswordHash
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || user == nil {
+		if user != nil {
+			if err := recordFailedLogin(user.ID, ip); err != nil {
+				logger.Errorf("Error recording failed login: %v", err)
+			}
+		}
+		sendErrorResponse(w, http.StatusUnauthorized, errInvalidCredentials.Error())
+		return
+	}
+
+	// 密码正确后才提示账号已停用
+	if user.DisabledAt != nil {
+		sendErrorResponse(w, http.StatusForbidden, "Account is disabled")
+		return
+	}
+	if err := resetFailedLogins(user.ID, ip); err != nil {
+		logger.Errorf("Error resetting failed logins: %v", err)
+	}
+
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		logger.Errorf("Error issuing tokens: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// refreshTokenHandler 轮换刷新令牌并签发新的访问令牌
+func refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.RefreshToken == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	tokens, err := rotateRefreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		if err == errInvalidToken {
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		} else {
+			logger.Errorf("Error refreshing token: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to refresh token")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// logoutHandler 吊销当前访问令牌，以及请求中提供的刷新令牌所在的令牌族
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	// 请求体可选
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	if req.RefreshToken != "" {
+		if err := revokeRefreshToken(req.RefreshToken); err != nil {
+			logger.Errorf("Error revoking refresh token: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+
+	if claims, ok := r.Context().Value(claimsContextKey).(*accessClaims); ok {
+		if err := revokeAccessToken(claims); err != nil {
+			logger.Errorf("Error revoking access token: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getCurrentUserHandler 返回当前登录的用户
+func getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(currentUser(r))
+}
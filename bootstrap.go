@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"task-scheduler/pkg/logger"
+)
+
+// seedAdmin 没有任何用户时，按 BOOTSTRAP_ADMIN_USERNAME、BOOTSTRAP_ADMIN_EMAIL 和 BOOTSTRAP_ADMIN_PASSWORD
+// 创建第一个管理员（邮箱视为已验证）；已有用户时不做任何事，因此可以一直保留这些配置
+func seedAdmin(ctx context.Context) error {
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	user := User{
+		Username: os.Getenv("BOOTSTRAP_ADMIN_USERNAME"),
+		Email:    os.Getenv("BOOTSTRAP_ADMIN_EMAIL"),
+		Password: os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"),
+		Role:     roleAdmin,
+	}
+	if user.Username == "" && user.Email == "" && user.Password == "" {
+		logger.Warnf("No users exist; set BOOTSTRAP_ADMIN_USERNAME, BOOTSTRAP_ADMIN_EMAIL and BOOTSTRAP_ADMIN_PASSWORD to create the first administrator")
+		return nil
+	}
+	if err := user.Validate(true); err != nil {
+		return fmt.Errorf("invalid bootstrap administrator: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = string(hash)
+	user.Password = ""
+	if err := CreateUser(ctx, &user); err != nil {
+		return err
+	}
+	if err := MarkEmailVerified(user.ID); err != nil {
+		return err
+	}
+
+	logger.Infof("Created bootstrap administrator %s (ID: %d)", user.Username, user.ID)
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/pkg/logger"
+)
+
+// loadCalendar 获取路由中命名空间下的日历，并校验当前用户在该命名空间拥有指定权限
+func loadCalendar(w http.ResponseWriter, r *http.Request, perm Permission) (*model.Calendar, bool) {
+	ns, ok := loadNamespace(w, r, perm)
+	if !ok {
+		return nil, false
+	}
+	id, ok := parseNamespaceResourceID(w, r, "calendarId", "Invalid calendar ID")
+	if !ok {
+		return nil, false
+	}
+
+	calendar, err := repository.NewCalendarRepository(jobDB.WithContext(r.Context())).Get(ns.ID, id)
+	if err != nil {
+		logger.Errorf("Error getting calendar: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Calendar not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get calendar")
+		}
+		return nil, false
+	}
+	return calendar, true
+}
+
+// getCalendarsHandler 获取命名空间中的日历
+func getCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	calendars, err := repository.NewCalendarRepository(jobDB.WithContext(r.Context())).List(ns.ID)
+	if err != nil {
+		logger.Errorf("Error getting calendars: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get calendars")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(calendars)
+}
+
+// getCalendarHandler 获取日历详情
+func getCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := loadCalendar(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(calendar)
+}
+
+// createCalendarHandler 在命名空间中创建日历
+func createCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	var calendar model.Calendar
+	if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+		logger.Errorf("Error decoding calendar: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := calendar.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+	calendar.ID = 0
+	calendar.NamespaceID = ns.ID
+
+	created, err := repository.NewCalendarRepository(jobDB.WithContext(r.Context())).Create(&calendar)
+	if err != nil {
+		logger.Errorf("Error creating calendar: %v", err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Calendar name already exists in this namespace")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to create calendar")
+		}
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceCalendar, created.ID, nil, created)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// updateCalendarHandler 修改日历名称、描述和日期
+func updateCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := loadCalendar(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	var req model.Calendar
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding calendar update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	before := *calendar
+	calendar.Name = req.Name
+	calendar.Description = req.Description
+	calendar.ExcludedDates = req.ExcludedDates
+	calendar, err := repository.NewCalendarRepository(jobDB.WithContext(r.Context())).Update(calendar)
+	if err != nil {
+		logger.Errorf("Error updating calendar: %v", err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Calendar name already exists in this namespace")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to update calendar")
+		}
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceCalendar, calendar.ID, &before, calendar)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(calendar)
+}
+
+// deleteCalendarHandler 删除日历，关联它的任务恢复每天执行
+func deleteCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := loadCalendar(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	if err := repository.NewCalendarRepository(jobDB.WithContext(r.Context())).Delete(calendar.NamespaceID, calendar.ID); err != nil {
+		logger.Errorf("Error deleting calendar: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete calendar")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceCalendar, calendar.ID, calendar, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
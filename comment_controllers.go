@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"task-scheduler/pkg/logger"
+)
+
+// commentRequest 创建/修改评论的请求体
+type commentRequest struct {
+	Body     string `json:"body"`
+	ParentID *int   `json:"parent_id"` // 仅创建时有效
+}
+
+// activitySortFields 动态只能按时间排序
+var activitySortFields = map[string]string{"at": "at"}
+
+// loadAccessibleTask 获取任务并校验当前用户能否访问
+func loadAccessibleTask(w http.ResponseWriter, r *http.Request, id int) (*Task, bool) {
+	task, err := GetTaskByID(r.Context(), id)
+	if err != nil {
+		logger.Errorf("Error checking task existence: %v", err)
+		sendError(w, err, "Failed to check task existence")
+		return nil, false
+	}
+	if !canAccessTask(currentUser(r), task) {
+		sendForbidden(w)
+		return nil, false
+	}
+	return task, true
+}
+
+// loadRouteTask 获取路由中的任务并校验访问权限
+func loadRouteTask(w http.ResponseWriter, r *http.Request) (*Task, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid task ID")
+		return nil, false
+	}
+	return loadAccessibleTask(w, r, id)
+}
+
+// loadEditableTask 获取路由中的任务并校验当前用户能否修改，用于评论、附件等写操作
+func loadEditableTask(w http.ResponseWriter, r *http.Request) (*Task, bool) {
+	task, ok := loadRouteTask(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !canEditTask(currentUser(r), task) {
+		sendForbidden(w)
+		return nil, false
+	}
+	return task, true
+}
+
+// loadComment 获取路由中的评论及其任务，并校验当前用户能否访问该任务
+func loadComment(w http.ResponseWriter, r *http.Request) (*Comment, *Task, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid comment ID")
+		return nil, nil, false
+	}
+
+	comment, err := GetCommentByID(id)
+	if err != nil {
+		logger.Errorf("Error getting comment: %v", err)
+		sendError(w, err, "Failed to get comment")
+		return nil, nil, false
+	}
+	task, ok := loadAccessibleTask(w, r, comment.TaskID)
+	if !ok {
+		return nil, nil, false
+	}
+	return comment, task, true
+}
+
+// getTaskCommentsHandler 获取任务的评论，按回复关系组织成树
+func getTaskCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadRouteTask(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := GetCommentsByTaskID(task.ID)
+	if err != nil {
+		logger.Errorf("Error getting comments: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get comments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(commentThreads(comments))
+}
+
+// createTaskCommentHandler 在任务上发表评论或回复，并通知 @ 提及的用户；关注者只能查看，不能评论
+func createTaskCommentHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadEditableTask(w, r)
+	if !ok {
+		return
+	}
+
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding comment: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	caller := currentUser(r)
+	comment := &Comment{TaskID: task.ID, ParentID: req.ParentID, AuthorID: &caller.ID, Body: strings.TrimSpace(req.Body)}
+	if err := comment.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	mentions, ok := resolveMentions(w, comment, task)
+	if !ok {
+		return
+	}
+	mentioned, err := CreateComment(comment, mentions)
+	if err != nil {
+		logger.Errorf("Error creating comment: %v", err)
+		sendError(w, err, "Failed to create comment")
+		return
+	}
+	notifyMentions(comment, task, caller, mentioned)
+	recordAudit(r, auditActionCreate, auditResourceComment, comment.ID, nil, comment)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// updateCommentHandler 修改评论，只有作者可以修改；只通知新增的提及
+func updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, task, ok := loadComment(w, r)
+	if !ok {
+		return
+	}
+	caller := currentUser(r)
+	if comment.AuthorID == nil || *comment.AuthorID != caller.ID {
+		sendForbidden(w)
+		return
+	}
+	if comment.Deleted {
+		sendError(w, errCommentDeleted, "")
+		return
+	}
+
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding comment update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	before := *comment
+	comment.Body = strings.TrimSpace(req.Body)
+	if err := comment.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	mentions, ok := resolveMentions(w, comment, task)
+	if !ok {
+		return
+	}
+	mentioned, err := UpdateComment(comment, mentions)
+	if err != nil {
+		logger.Errorf("Error updating comment: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update comment")
+		return
+	}
+	notifyMentions(comment, task, caller, mentioned)
+	recordAudit(r, auditActionUpdate, auditResourceComment, comment.ID, &before, comment)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// resolveMentions 解析评论正文中的提及，在写入评论之前调用，提及随评论在同一事务中保存
+func resolveMentions(w http.ResponseWriter, comment *Comment, task *Task) ([]User, bool) {
+	users, err := mentionedUsers(comment.Body, task)
+	if err != nil {
+		logger.Errorf("Error resolving mentions: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save mentions")
+		return nil, false
+	}
+	return users, true
+}
+
+// deleteCommentHandler 删除评论，仍能修改任务的作者或拥有权限的用户可以删除
+func deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, task, ok := loadComment(w, r)
+	if !ok {
+		return
+	}
+	caller := currentUser(r)
+	isAuthor := comment.AuthorID != nil && *comment.AuthorID == caller.ID
+	if !canEditTask(caller, task) || (!isAuthor && !hasPermission(caller, permManageAllTodos)) {
+		sendForbidden(w)
+		return
+	}
+
+	if err := DeleteComment(comment.ID); err != nil {
+		logger.Errorf("Error deleting comment: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete comment")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceComment, comment.ID, comment, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getTaskActivityHandler 按游标分页获取任务动态（评论与字段变更），默认按时间倒序；
+// 下一页的地址通过 Link 头给出，不支持 offset
+func getTaskActivityHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadRouteTask(w, r)
+	if !ok {
+		return
+	}
+	page, msg := parsePage(r, activitySortFields, "-at")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	if page.Offset > 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "Activity is paginated with cursor, offset is not supported")
+		return
+	}
+
+	var after *activityKey
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, err := parseActivityCursor(v)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		after = cursor
+	}
+
+	items, next, err := GetTaskActivity(task.ID, strings.HasPrefix(page.OrderBy, "at ASC"), after, page.Limit)
+	if err != nil {
+		logger.Errorf("Error getting task activity: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get task activity")
+		return
+	}
+
+	if next != nil {
+		u := *r.URL
+		query := u.Query()
+		query.Set("limit", strconv.Itoa(page.Limit))
+		query.Set("cursor", next.String())
+		u.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-scheduler/pkg/logger"
+	"task-scheduler/pkg/mail"
+)
+
+const (
+	maxCommentLength   = 10000
+	maxCommentMentions = 20
+)
+
+// mentionPattern 评论中的 @用户名，用户名规则与 usernamePattern 一致
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.-])@([A-Za-z0-9_.-]{3,32})`)
+
+// Comment 任务上的评论，ParentID 不为空时为对另一条评论的回复
+type Comment struct {
+	ID        int        `json:"id"`
+	TaskID    int        `json:"task_id"`
+	ParentID  *int       `json:"parent_id,omitempty"`
+	AuthorID  *int       `json:"author_id"` // 作者账号删除后为空
+	Body      string     `json:"body"`
+	Mentions  []int      `json:"mentions"` // 被提及的用户ID
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	Deleted   bool       `json:"deleted"` // 已删除的评论保留位置以维持回复的层级，正文为空
+	Replies   []Comment  `json:"replies,omitempty"`
+}
+
+// 动态的类型
+const (
+	activityComment = "comment"
+	activityCreated = "created"
+	activityUpdated = "updated"
+)
+
+// ActivityItem 任务动态中的一项：评论或一次字段变更
+type ActivityItem struct {
+	Type      string                 `json:"type"`
+	At        time.Time              `json:"at"`
+	ActorID   *int                   `json:"actor_id,omitempty"`
+	ActorName string                 `json:"actor_name,omitempty"`
+	Comment   *Comment               `json:"comment,omitempty"`
+	Changes   map[string]AuditChange `json:"changes,omitempty"`
+}
+
+// activityFields 动态中展示的任务字段，其余字段（版本号、排序等）的变化不展示
+var activityFields = map[string]bool{
+	"title": true, "description": true, "completed": true, "user_id": true, "due_at": true,
+	"priority": true, "project_id": true, "tag_ids": true, "parent_id": true, "status": true,
+	"checklist": true, "recurrence": true, "assignee_ids": true, "watcher_ids": true,
+}
+
+var (
+	errCommentNotFound = &appError{Kind: errNotFound, Code: "comment_not_found", Message: "Comment not found"}
+	errCommentDeleted  = &appError{Kind: errConflict, Code: "comment_deleted", Message: "Comment has been deleted"}
+)
+
+const commentColumns = "id, task_id, parent_id, author_id, body, created_at, edited_at, deleted_at IS NOT NULL"
+
+// scanComment 从查询结果中读取评论（不含提及）
+func scanComment(scanner interface{ Scan(...interface{}) error }) (*Comment, error) {
+	var c Comment
+	err := scanner.Scan(&c.ID, &c.TaskID, &c.ParentID, &c.AuthorID, &c.Body, &c.CreatedAt, &c.EditedAt, &c.Deleted)
+	if err != nil {
+		return nil, err
+	}
+	c.Mentions = []int{}
+	return &c, nil
+}
+
+// CreateComment 创建评论并保存提及，两者在同一事务中写入；回复的评论必须属于同一任务。返回被提及的用户
+func CreateComment(c *Comment, mentions []User) ([]User, error) {
+	if c.ParentID != nil {
+		var taskID int
+		err := db.QueryRow("SELECT task_id FROM task_comments WHERE id = ?", *c.ParentID).Scan(&taskID)
+		if err == sql.ErrNoRows || (err == nil && taskID != c.TaskID) {
+			return nil, errCommentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createdAt := time.Now()
+	result, err := tx.Exec(
+		"INSERT INTO task_comments (task_id, parent_id, author_id, body, created_at) VALUES (?, ?, ?, ?, ?)",
+		c.TaskID, c.ParentID, c.AuthorID, c.Body, createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	c.ID = int(id)
+	added, err := saveMentions(tx, c, mentions)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	c.CreatedAt = createdAt
+	return added, nil
+}
+
+// GetCommentByID 根据ID获取评论
+func GetCommentByID(id int) (*Comment, error) {
+	c, err := scanComment(db.QueryRow("SELECT "+commentColumns+" FROM task_comments WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errCommentNotFound
+		}
+		return nil, err
+	}
+	comments := []*Comment{c}
+	if err := loadMentions(comments); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpdateComment 修改评论正文并更新提及，两者在同一事务中写入。返回新增的被提及用户
+func UpdateComment(c *Comment, mentions []User) ([]User, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec("UPDATE task_comments SET body = ?, edited_at = ? WHERE id = ? AND deleted_at IS NULL", c.Body, now, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	added, err := saveMentions(tx, c, mentions)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	c.EditedAt = &now
+	return added, nil
+}
+
+// DeleteComment 删除评论：有回复时只清空正文保留位置，否则直接删除
+func DeleteComment(id int) error {
+	var replies bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM task_comments WHERE parent_id = ?)", id).Scan(&replies); err != nil {
+		return err
+	}
+	if !replies {
+		_, err := db.Exec("DELETE FROM task_comments WHERE id = ?", id)
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE task_comments SET body = '', deleted_at = ? WHERE id = ?", time.Now(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCommentsByTaskID 获取任务的全部评论，按创建时间排序
+func GetCommentsByTaskID(taskID int) ([]*Comment, error) {
+	rows, err := db.Query("SELECT "+commentColumns+" FROM task_comments WHERE task_id = ? ORDER BY created_at, id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, loadMentions(comments)
+}
+
+// commentThreads 将评论按回复关系组织成树，返回顶层评论
+func commentThreads(comments []*Comment) []Comment {
+	children := make(map[int][]*Comment)
+	var roots []*Comment
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func(c *Comment) Comment
+	build = func(c *Comment) Comment {
+		thread := *c
+		for _, reply := range children[c.ID] {
+			thread.Replies = append(thread.Replies, build(reply))
+		}
+		return thread
+	}
+	threads := []Comment{}
+	for _, c := range roots {
+		threads = append(threads, build(c))
+	}
+	return threads
+}
+
+// loadMentions 为一组评论填充被提及的用户
+func loadMentions(comments []*Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	index := make(map[int]*Comment, len(comments))
+	args := make([]interface{}, len(comments))
+	for i, c := range comments {
+		index[c.ID] = c
+		args[i] = c.ID
+	}
+	rows, err := db.Query(
+		"SELECT comment_id, user_id FROM comment_mentions WHERE comment_id IN ("+placeholders(len(comments))+") ORDER BY user_id",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID, userID int
+		if err := rows.Scan(&commentID, &userID); err != nil {
+			return err
+		}
+		if c, ok := index[commentID]; ok {
+			c.Mentions = append(c.Mentions, userID)
+		}
+	}
+	return rows.Err()
+}
+
+// mentionedUsers 解析正文中 @ 提及的用户，只保留未停用且能访问该任务的用户
+func mentionedUsers(body string, task *Task) ([]User, error) {
+	seen := make(map[string]bool)
+	var args []interface{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] && len(args) < maxCommentMentions {
+			seen[m[1]] = true
+			args = append(args, m[1])
+		}
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	rows, err := db.Query(
+		"SELECT id, username, email, role FROM users WHERE disabled_at IS NULL AND username IN ("+placeholders(len(args))+")",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role); err != nil {
+			return nil, err
+		}
+		if canAccessTask(&u, task) {
+			users = append(users, u)
+		}
+	}
+	return users, rows.Err()
+}
+
+// saveMentions 在调用方的事务中保存评论的提及，返回新增的被提及用户
+func saveMentions(tx *sql.Tx, c *Comment, users []User) ([]User, error) {
+	previous := make(map[int]bool)
+	for _, id := range c.Mentions {
+		previous[id] = true
+	}
+	if _, err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", c.ID); err != nil {
+		return nil, err
+	}
+
+	var added []User
+	c.Mentions = []int{}
+	for _, u := range users {
+		if _, err := tx.Exec("INSERT INTO comment_mentions (comment_id, user_id) VALUES (?, ?)", c.ID, u.ID); err != nil {
+			return nil, err
+		}
+		c.Mentions = append(c.Mentions, u.ID)
+		if !previous[u.ID] {
+			added = append(added, u)
+		}
+	}
+	sort.Ints(c.Mentions)
+	return added, nil
+}
+
+// notifyMentions 在后台通知被提及的用户，发送失败只记录日志；作者提及自己时不通知
+func notifyMentions(c *Comment, task *Task, author *User, users []User) {
+	for _, u := range users {
+		if u.ID == author.ID {
+			continue
+		}
+		msg := mail.Message{
+			To:      u.Email,
+			Subject: fmt.Sprintf("%s mentioned you on \"%s\"", author.Username, task.Title),
+			Body: fmt.Sprintf("Hi %s,\n\n%s mentioned you in a comment:\n\n%s\n\n%s/tasks/%d#comment-%d\n",
+				u.Username, author.Username, c.Body, appBaseURL(), task.ID, c.ID),
+		}
+		userID := u.ID
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+			defer cancel()
+			if err := mailSender.Send(ctx, msg); err != nil {
+				logger.Errorf("Failed to send mention of comment %d to user %d: %v", c.ID, userID, err)
+			}
+		}()
+	}
+}
+
+// 动态在分页游标中的来源，用于区分同一时刻的评论和审计日志
+const (
+	activitySourceAudit   = "audit"
+	activitySourceComment = "comment"
+)
+
+// activityKey 动态的排序键，也是分页游标的内容：时间、来源和来源表中的ID
+type activityKey struct {
+	At     time.Time
+	Source string
+	ID     int64
+}
+
+// String 将排序键编码为不透明的游标
+func (k activityKey) String() string {
+	raw := k.At.UTC().Format(time.RFC3339Nano) + "|" + k.Source + "|" + strconv.FormatInt(k.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseActivityCursor 解析 activityKey.String 生成的游标
+func parseActivityCursor(cursor string) (*activityKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || (parts[1] != activitySourceAudit && parts[1] != activitySourceComment) {
+		return nil, fmt.Errorf("malformed activity cursor")
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &activityKey{At: at, Source: parts[1], ID: id}, nil
+}
+
+// GetTaskActivity 按时间倒序（ascending 时正序）返回任务在游标 after 之后的至多 limit 项动态，
+// 以及下一页的游标（没有更多动态时为 nil）。评论与审计日志在 SQL 中合并排序并按游标分页；
+// 只改动了不展示字段的更新不计入动态，一批中被跳过的项由后续批次补足
+func GetTaskActivity(taskID int, ascending bool, after *activityKey, limit int) ([]ActivityItem, *activityKey, error) {
+	items := []ActivityItem{}
+	var last activityKey
+	cursor := after
+	for {
+		want := limit + 1 - len(items)
+		keys, err := activityKeys(taskID, ascending, cursor, want)
+		if err != nil {
+			return nil, nil, err
+		}
+		loaded, err := loadActivity(taskID, keys)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, key := range keys {
+			item, ok := loaded[key.Source+strconv.FormatInt(key.ID, 10)]
+			if !ok {
+				continue
+			}
+			if len(items) == limit {
+				return items, &last, nil
+			}
+			items = append(items, *item)
+			last = key
+		}
+		if len(keys) < want {
+			return items, nil, nil
+		}
+		cursor = &keys[len(keys)-1]
+	}
+}
+
+// activityKeys 按排序键分页读取任务的评论和任务审计日志（创建与更新）的键
+func activityKeys(taskID int, ascending bool, after *activityKey, limit int) ([]activityKey, error) {
+	dir, cmp := "DESC", "<"
+	if ascending {
+		dir, cmp = "ASC", ">"
+	}
+	query := `SELECT at, source, id FROM (
+		SELECT created_at AS at, '` + activitySourceComment + `' AS source, id
+		FROM task_comments WHERE task_id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT created_at, '` + activitySourceAudit + `', id
+		FROM audit_logs WHERE resource_type = ? AND resource_id = ? AND action IN (?, ?)
+	) activity`
+	args := []interface{}{taskID, auditResourceTask, strconv.Itoa(taskID), auditActionCreate, auditActionUpdate}
+	if after != nil {
+		query += " WHERE (at, source, id) " + cmp + " (?, ?, ?)"
+		args = append(args, after.At, after.Source, after.ID)
+	}
+	query += " ORDER BY at " + dir + ", source " + dir + ", id " + dir + " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []activityKey
+	for rows.Next() {
+		var k activityKey
+		if err := rows.Scan(&k.At, &k.Source, &k.ID); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// loadActivity 读取一批键对应的评论和审计日志，返回 来源+ID -> 动态项；不展示的更新不在结果中
+func loadActivity(taskID int, keys []activityKey) (map[string]*ActivityItem, error) {
+	var commentIDs, auditIDs []interface{}
+	for _, k := range keys {
+		if k.Source == activitySourceComment {
+			commentIDs = append(commentIDs, k.ID)
+		} else {
+			auditIDs = append(auditIDs, k.ID)
+		}
+	}
+
+	loaded := make(map[string]*ActivityItem, len(keys))
+	if len(commentIDs) > 0 {
+		rows, err := db.Query(
+			"SELECT "+commentColumns+" FROM task_comments WHERE id IN ("+placeholders(len(commentIDs))+")",
+			commentIDs...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		var comments []*Comment
+		for rows.Next() {
+			c, err := scanComment(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			comments = append(comments, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if err := loadMentions(comments); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			loaded[activitySourceComment+strconv.Itoa(c.ID)] = &ActivityItem{
+				Type: activityComment, At: c.CreatedAt, ActorID: c.AuthorID, Comment: c,
+			}
+		}
+	}
+
+	if len(auditIDs) > 0 {
+		filter := AuditFilter{ResourceType: auditResourceTask, ResourceID: strconv.Itoa(taskID), IDs: auditIDs}
+		err := EachAuditEntry(filter, func(entry *AuditEntry) error {
+			item, err := auditActivityItem(entry)
+			if err != nil || item == nil {
+				return err
+			}
+			loaded[activitySourceAudit+strconv.FormatInt(entry.ID, 10)] = item
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return loaded, nil
+}
+
+// auditActivityItem 将任务的审计日志转换为动态项，只改动了不展示字段的更新返回 nil
+func auditActivityItem(entry *AuditEntry) (*ActivityItem, error) {
+	item := &ActivityItem{At: entry.CreatedAt, ActorID: entry.ActorID, ActorName: entry.ActorName}
+	switch entry.Action {
+	case auditActionCreate:
+		item.Type = activityCreated
+	case auditActionUpdate:
+		item.Type = activityUpdated
+	default:
+		return nil, nil
+	}
+
+	if len(entry.Changes) > 0 && item.Type == activityUpdated {
+		var changes map[string]AuditChange
+		if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+			return nil, err
+		}
+		item.Changes = make(map[string]AuditChange)
+		for field, change := range changes {
+			if activityFields[field] {
+				item.Changes[field] = change
+			}
+		}
+		if len(item.Changes) == 0 {
+			return nil, nil
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestActivityCursorRoundTrip(t *testing.T) {
+	key := activityKey{At: time.Date(2026, 3, 1, 9, 30, 0, 123456000, time.UTC), Source: activitySourceAudit, ID: 42}
+	got, err := parseActivityCursor(key.String())
+	if err != nil {
+		t.Fatalf("parseActivityCursor() error = %v", err)
+	}
+	if !got.At.Equal(key.At) || got.Source != key.Source || got.ID != key.ID {
+		t.Errorf("parseActivityCursor() = %+v, want %+v", got, key)
+	}
+
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("2026-03-01T09:30:00Z|comment")),
+		base64.RawURLEncoding.EncodeToString([]byte("2026-03-01T09:30:00Z|webhook|1")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday|comment|1")),
+		base64.RawURLEncoding.EncodeToString([]byte("2026-03-01T09:30:00Z|comment|x")),
+	} {
+		if _, err := parseActivityCursor(cursor); err == nil {
+			t.Errorf("parseActivityCursor(%q) should fail", cursor)
+		}
+	}
+}
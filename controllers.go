@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 	"golang.org/x/crypto/bcrypt"
 	"github.com/gorilla/mux"
 )
@@ -28,42 +30,77 @@ func createUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 验证请求数据
-	if user.Username == "" || user.Email == "" || user.PasswordHash == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "Username, email and password are required")
+	if user.Role == "" {
+		user.Role = roleViewer
+	}
+	if err := user.Validate(true); err != nil {
+		sendError(w, err, "Invalid request")
 		return
 	}
 
 	// 哈希密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.PasswordHash), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		logger.Errorf("Error hashing password: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to process password")
 		return
 	}
 	user.PasswordHash = string(hashedPassword)
+	user.Password = ""
 
 	// 创建用户
-	err = CreateUser(&user)
+	err = CreateUser(r.Context(), &user)
 	if err != nil {
 		logger.Errorf("Error creating user: %v", err)
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
+		sendError(w, err, "Failed to create user")
 		return
 	}
+	recordAudit(r, auditActionCreate, auditResourceUser, user.ID, nil, &user)
+
+	// 发送邮箱验证邮件，失败不影响创建
+	if err := sendVerificationEmail(&user); err != nil {
+		logger.Errorf("Error sending verification email: %v", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
 }
 
-// getAllUsersHandler 获取所有用户
+// parseUserFilter 从查询参数解析用户列表的过滤条件
+func parseUserFilter(r *http.Request) (UserFilter, string) {
+	query := r.URL.Query()
+	filter := UserFilter{Role: query.Get("role"), Query: query.Get("q")}
+	if filter.Role != "" && !validRole(filter.Role) {
+		return filter, "Invalid role"
+	}
+
+	var msg string
+	filter.CreatedFrom, filter.CreatedTo, msg = parseTimeRange(r, "created")
+	return filter, msg
+}
+
+// getAllUsersHandler 分页获取用户，支持按角色、创建时间和用户名/邮箱文本过滤
 func getAllUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := GetAllUsers()
+	filter, msg := parseUserFilter(r)
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	page, msg := parsePage(r, userSortFields, "id")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	users, total, err := ListUsers(r.Context(), filter, page)
 	if err != nil {
 		logger.Errorf("Error getting users: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get users")
 		return
 	}
 
+	setPageHeaders(w, r, page, total)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(users)
@@ -78,24 +115,53 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := GetUserByID(id)
+	// 只能查看自己，管理员除外
+	if !canAccessUser(currentUser(r), id, permManageUsers) {
+		sendForbidden(w)
+		return
+	}
+
+	user, err := GetUserByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error getting user: %v", err)
-		if err.Error() == "user not found" {
-			sendErrorResponse(w, http.StatusNotFound, "User not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get user")
-		}
+		sendError(w, err, "Failed to get user")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(user)
 }
 
-// updateUserHandler 更新用户
+// updateUserHandler 整体更新用户（PUT），未提供的角色保持不变
 func updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	modifyUser(w, r, func(existing *User, body []byte) (*User, error) {
+		var updatedUser User
+		if err := json.Unmarshal(body, &updatedUser); err != nil {
+			return nil, err
+		}
+		if updatedUser.Role == "" {
+			updatedUser.Role = existing.Role
+		}
+		return &updatedUser, nil
+	})
+}
+
+// patchUserHandler 按 JSON Merge Patch 部分更新用户（PATCH）
+func patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	modifyUser(w, r, func(existing *User, body []byte) (*User, error) {
+		var updatedUser User
+		if err := applyMergePatch(existing, body, &updatedUser); err != nil {
+			return nil, err
+		}
+		return &updatedUser, nil
+	})
+}
+
+// modifyUser 更新用户的公共流程：build 根据现有用户和请求体生成更新后的用户。
+// 请求的 If-Match 或请求体中的 version 与当前版本不一致时返回 412
+func modifyUser(w http.ResponseWriter, r *http.Request, build func(existing *User, body []byte) (*User, error)) {
 	params := mux.Vars(r)
 	id, err := strconv.Atoi(params["id"])
 	if err != nil {
@@ -103,59 +169,110 @@ func updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能修改自己，管理员除外
+	caller := currentUser(r)
+	if !canAccessUser(caller, id, permManageUsers) {
+		sendForbidden(w)
+		return
+	}
+
 	// 检查用户是否存在
-	existingUser, err := GetUserByID(id)
+	existingUser, err := GetUserByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error checking user existence: %v", err)
-		if err.Error() == "user not found" {
-			sendErrorResponse(w, http.StatusNotFound, "User not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check user existence")
-		}
+		sendError(w, err, "Failed to check user existence")
+		return
+	}
+	if !checkIfMatch(w, r, existingUser.Version) {
+		return
+	}
+	existingHash, err := GetUserPasswordHash(r.Context(), id)
+	if err != nil {
+		logger.Errorf("Error checking user existence: %v", err)
+		sendError(w, err, "Failed to check user existence")
 		return
 	}
 
-	var updatedUser User
-	err = json.NewDecoder(r.Body).Decode(&updatedUser)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading user update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	updatedUser, err := build(existingUser, body)
 	if err != nil {
 		logger.Errorf("Error decoding user update: %v", err)
 		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// 保留原ID
+	// 保留原ID；请求体中的版本号只用于并发校验
 	updatedUser.ID = id
+	if updatedUser.Version != 0 && updatedUser.Version != existingUser.Version {
+		sendError(w, errVersionConflict, "")
+		return
+	}
+	updatedUser.Version = existingUser.Version
+	// 只有管理员可以修改角色
+	if updatedUser.Role != existingUser.Role && !hasPermission(caller, permManageUsers) {
+		sendForbidden(w)
+		return
+	}
+	if err := updatedUser.Validate(false); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
 	// 如果提供了新密码，则哈希新密码
-	if updatedUser.PasswordHash != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updatedUser.PasswordHash), bcrypt.DefaultCost)
+	passwordChanged := updatedUser.Password != ""
+	if passwordChanged {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updatedUser.Password), bcrypt.DefaultCost)
 		if err != nil {
 			logger.Errorf("Error hashing password: %v", err)
 			sendErrorResponse(w, http.StatusInternalServerError, "Failed to process password")
 			return
 		}
 		updatedUser.PasswordHash = string(hashedPassword)
+		updatedUser.Password = ""
 	} else {
 		// 否则使用原密码
-		updatedUser.PasswordHash = existingUser.PasswordHash
+		updatedUser.PasswordHash = existingHash
 	}
 
 	// 更新用户
-	err = UpdateUser(&updatedUser)
+	err = UpdateUser(r.Context(), updatedUser)
 	if err != nil {
 		logger.Errorf("Error updating user: %v", err)
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update user")
+		sendError(w, err, "Failed to update user")
 		return
 	}
 
+	// 修改密码后吊销该用户已签发的刷新令牌
+	if passwordChanged {
+		if err := revokeUserTokens(id); err != nil {
+			logger.Errorf("Error revoking user tokens: %v", err)
+		}
+	}
+
+	// 修改邮箱后需要重新验证
+	if updatedUser.Email != existingUser.Email {
+		if err := ClearEmailVerification(id); err != nil {
+			logger.Errorf("Error clearing email verification: %v", err)
+		} else if err := sendVerificationEmail(updatedUser); err != nil {
+			logger.Errorf("Error sending verification email: %v", err)
+		}
+	}
+
 	// 返回更新后的用户信息（不含密码）
-	resultUser, err := GetUserByID(id)
+	resultUser, err := GetUserByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error getting updated user: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get updated user")
 		return
 	}
+	recordAudit(r, auditActionUpdate, auditResourceUser, id, existingUser, resultUser)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(resultUser.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resultUser)
 }
@@ -170,24 +287,21 @@ func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查用户是否存在
-	_, err = GetUserByID(id)
+	user, err := GetUserByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error checking user existence: %v", err)
-		if err.Error() == "user not found" {
-			sendErrorResponse(w, http.StatusNotFound, "User not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check user existence")
-		}
+		sendError(w, err, "Failed to check user existence")
 		return
 	}
 
 	// 删除用户
-	err = DeleteUser(id)
+	err = DeleteUser(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error deleting user: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
+	recordAudit(r, auditActionDelete, auditResourceUser, id, user, nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNoContent)
@@ -206,50 +320,238 @@ func createTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 验证请求数据
-	if task.Title == "" || task.UserID == 0 {
-		sendErrorResponse(w, http.StatusBadRequest, "Title and user ID are required")
+	if err := task.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	// 未指定用户时归属当前用户，为他人创建任务需要权限
+	caller := currentUser(r)
+	task.CreatedBy = &caller.ID
+	if task.UserID == 0 {
+		task.UserID = caller.ID
+	} else if !canAccessUser(caller, task.UserID, permManageAllTodos) {
+		sendForbidden(w)
 		return
 	}
 
 	// 检查用户是否存在
-	_, err = GetUserByID(task.UserID)
+	_, err = GetUserByID(r.Context(), task.UserID)
 	if err != nil {
 		logger.Errorf("Error checking user existence: %v", err)
-		if err.Error() == "user not found" {
-			sendErrorResponse(w, http.StatusNotFound, "User not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check user existence")
-		}
+		sendError(w, err, "Failed to check user existence")
+		return
+	}
+	if err := checkTaskLabels(&task); err != nil {
+		logger.Errorf("Error checking task labels: %v", err)
+		sendError(w, err, "Failed to check task labels")
+		return
+	}
+	if err := checkTaskPeople(&task); err != nil {
+		logger.Errorf("Error checking task assignees: %v", err)
+		sendError(w, err, "Failed to check task assignees")
+		return
+	}
+	if err := checkTaskParent(&task); err != nil {
+		logger.Errorf("Error checking parent task: %v", err)
+		sendError(w, err, "Failed to check parent task")
+		return
+	}
+	if err := resolveTaskStatus(nil, &task); err != nil {
+		logger.Errorf("Error resolving task status: %v", err)
+		sendError(w, err, "Failed to resolve task status")
 		return
 	}
 
 	// 创建任务
-	err = CreateTask(&task)
+	err = CreateTask(r.Context(), &task)
 	if err != nil {
 		logger.Errorf("Error creating task: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create task")
 		return
 	}
+	if err := recordStatusChange(&task, "", &caller.ID); err != nil {
+		logger.Errorf("Error recording status of task %d: %v", task.ID, err)
+	}
+	recordAudit(r, auditActionCreate, auditResourceTask, task.ID, nil, &task)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(task)
 }
 
-// getAllTasksHandler 获取所有任务
-func getAllTasksHandler(w http.ResponseWriter, r *http.Request) {
-	tasks, err := GetAllTasks()
+// parseTaskFilter 从查询参数解析任务列表的过滤条件和分页参数
+func parseTaskFilter(r *http.Request, defaultSort string) (TaskFilter, Page, string) {
+	query := r.URL.Query()
+	filter := TaskFilter{Query: query.Get("q"), Priority: query.Get("priority"), Status: query.Get("status")}
+	switch filter.Priority {
+	case "", priorityLow, priorityNormal, priorityHigh, priorityUrgent:
+	default:
+		return filter, Page{}, "Invalid priority"
+	}
+
+	if v := query.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return filter, Page{}, "Invalid user_id"
+		}
+		filter.UserID = id
+	}
+	if v := query.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, Page{}, "completed must be true or false"
+		}
+		filter.Completed = &completed
+	}
+
+	var msg string
+	if filter.CreatedFrom, filter.CreatedTo, msg = parseTimeRange(r, "created"); msg != "" {
+		return filter, Page{}, msg
+	}
+	if filter.UpdatedFrom, filter.UpdatedTo, msg = parseTimeRange(r, "updated"); msg != "" {
+		return filter, Page{}, msg
+	}
+	if filter.DueFrom, filter.DueTo, msg = parseTimeRange(r, "due"); msg != "" {
+		return filter, Page{}, msg
+	}
+
+	// project_id=inbox 查询不属于任何项目的任务
+	switch v := query.Get("project_id"); v {
+	case "":
+	case projectTasksInbox:
+		inbox := 0
+		filter.ProjectID = &inbox
+	default:
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return filter, Page{}, "Invalid project_id"
+		}
+		filter.ProjectID = &id
+	}
+	seen := make(map[int]bool)
+	for _, v := range splitList(query.Get("tag")) {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return filter, Page{}, "Invalid tag"
+		}
+		if !seen[id] {
+			seen[id] = true
+			filter.TagIDs = append(filter.TagIDs, id)
+		}
+	}
+	// parent_id=none 只查询顶层任务
+	switch v := query.Get("parent_id"); v {
+	case "":
+	case "none":
+		root := 0
+		filter.ParentID = &root
+	default:
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return filter, Page{}, "Invalid parent_id"
+		}
+		filter.ParentID = &id
+	}
+	switch query.Get("tag_match") {
+	case "", "any":
+	case "all":
+		filter.TagMatchAll = true
+	default:
+		return filter, Page{}, "tag_match must be any or all"
+	}
+
+	page, msg := parsePage(r, taskSortFields, defaultSort)
+	return filter, page, msg
+}
+
+// scopeTaskFilter 普通用户只能查询自己的任务，查询他人时返回 403
+func scopeTaskFilter(w http.ResponseWriter, r *http.Request, filter *TaskFilter) bool {
+	caller := currentUser(r)
+	if hasPermission(caller, permManageAllTodos) {
+		return true
+	}
+	if filter.UserID != 0 && filter.UserID != caller.ID {
+		sendForbidden(w)
+		return false
+	}
+	filter.UserID = caller.ID
+	return true
+}
+
+// writeTaskPage 查询一页任务并写入响应
+func writeTaskPage(w http.ResponseWriter, r *http.Request, filter TaskFilter, page Page) {
+	tasks, total, err := ListTasks(r.Context(), filter, page)
 	if err != nil {
 		logger.Errorf("Error getting tasks: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get tasks")
 		return
 	}
 
+	setPageHeaders(w, r, page, total)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(tasks)
 }
 
+// getAllTasksHandler 分页获取任务，普通用户只能看到自己的任务
+func getAllTasksHandler(w http.ResponseWriter, r *http.Request) {
+	filter, page, msg := parseTaskFilter(r, "id")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !scopeTaskFilter(w, r, &filter) {
+		return
+	}
+
+	writeTaskPage(w, r, filter, page)
+}
+
+// getOverdueTasksHandler 获取已过截止时间且未完成的任务，默认按截止时间排序
+func getOverdueTasksHandler(w http.ResponseWriter, r *http.Request) {
+	filter, page, msg := parseTaskFilter(r, "due_at")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !scopeTaskFilter(w, r, &filter) {
+		return
+	}
+
+	now := time.Now()
+	completed := false
+	filter.Completed = &completed
+	filter.DueTo = &now
+	writeTaskPage(w, r, filter, page)
+}
+
+// getTasksDueTodayHandler 获取今天到期的任务，tz 参数指定“今天”所在的时区（IANA 名称）
+func getTasksDueTodayHandler(w http.ResponseWriter, r *http.Request) {
+	filter, page, msg := parseTaskFilter(r, "due_at")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !scopeTaskFilter(w, r, &filter) {
+		return
+	}
+
+	loc := time.Local
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid tz")
+			return
+		}
+	}
+	y, m, d := time.Now().In(loc).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+	filter.DueFrom, filter.DueTo = &start, &end
+	writeTaskPage(w, r, filter, page)
+}
+
 // getTaskHandler 根据ID获取任务
 func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
@@ -259,23 +561,25 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := GetTaskByID(id)
+	task, err := GetTaskByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error getting task: %v", err)
-		if err.Error() == "task not found" {
-			sendErrorResponse(w, http.StatusNotFound, "Task not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get task")
-		}
+		sendError(w, err, "Failed to get task")
+		return
+	}
+	if !canAccessTask(currentUser(r), task) {
+		sendForbidden(w)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(task)
 }
 
-// getTasksByUserHandler 根据用户ID获取任务
+// getTasksByUserHandler 分页获取指定用户的任务，过滤和排序参数与任务列表相同；
+// role 参数按用户与任务的关系筛选：owner（默认）、created、assigned、watching
 func getTasksByUserHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userID, err := strconv.Atoi(params["userId"])
@@ -284,32 +588,124 @@ func getTasksByUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能查看自己的任务，除非拥有权限
+	if !canAccessUser(currentUser(r), userID, permManageAllTodos) {
+		sendForbidden(w)
+		return
+	}
+
 	// 检查用户是否存在
-	_, err = GetUserByID(userID)
+	_, err = GetUserByID(r.Context(), userID)
 	if err != nil {
 		logger.Errorf("Error checking user existence: %v", err)
-		if err.Error() == "user not found" {
-			sendErrorResponse(w, http.StatusNotFound, "User not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check user existence")
-		}
+		sendError(w, err, "Failed to check user existence")
+		return
+	}
+
+	filter, page, msg := parseTaskFilter(r, "id")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	// 按所有者筛选时路径中的用户ID优先于 user_id 参数
+	if !applyTaskRole(&filter, r.URL.Query().Get("role"), userID) {
+		sendErrorResponse(w, http.StatusBadRequest, "role must be owner, created, assigned or watching")
+		return
+	}
+
+	writeTaskPage(w, r, filter, page)
+}
+
+// getMyTasksHandler 分页获取当前用户负责或关注的任务，role=assigned|watching 时只看其中一种
+func getMyTasksHandler(w http.ResponseWriter, r *http.Request) {
+	filter, page, msg := parseTaskFilter(r, "id")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	caller := currentUser(r)
+	switch role := r.URL.Query().Get("role"); role {
+	case "":
+		filter.InvolvedID = caller.ID
+	case taskRoleAssigned, taskRoleWatching:
+		applyTaskRole(&filter, role, caller.ID)
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "role must be assigned or watching")
 		return
 	}
 
-	tasks, err := GetTasksByUserID(userID)
+	writeTaskPage(w, r, filter, page)
+}
+
+// getSubtasksHandler 分页获取任务的直接子任务
+func getSubtasksHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
 	if err != nil {
-		logger.Errorf("Error getting user tasks: %v", err)
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get user tasks")
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	task, err := GetTaskByID(r.Context(), id)
+	if err != nil {
+		logger.Errorf("Error checking task existence: %v", err)
+		sendError(w, err, "Failed to check task existence")
+		return
+	}
+	if !canAccessTask(currentUser(r), task) {
+		sendForbidden(w)
+		return
+	}
+
+	filter, page, msg := parseTaskFilter(r, "id")
+	if msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	filter.UserID = task.UserID
+	filter.ParentID = &id
+
+	writeTaskPage(w, r, filter, page)
 }
 
-// updateTaskHandler 更新任务
+// updateTaskHandler 更新任务（PUT）。请求体中省略的字段一律保留原值（包括 user_id、recurrence、project_id 和 tag_ids），
+// 不认识新字段的客户端不会因此清空任务的项目、标签或负责人；显式传 null 或空数组才会清除
 func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	modifyTask(w, r, func(existing *Task, body []byte) (*Task, error) {
+		// 在原任务的副本上解码，json 只覆盖请求体中出现的字段；经 JSON 复制避免与原任务共享切片
+		current, err := json.Marshal(existing)
+		if err != nil {
+			return nil, err
+		}
+		var updatedTask Task
+		if err := json.Unmarshal(current, &updatedTask); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &updatedTask); err != nil {
+			return nil, invalidBody(err)
+		}
+		// 与之前一致，user_id 为 0 表示不转移
+		if updatedTask.UserID == 0 {
+			updatedTask.UserID = existing.UserID
+		}
+		return &updatedTask, nil
+	})
+}
+
+// patchTaskHandler 按 JSON Merge Patch 部分更新任务（PATCH）
+func patchTaskHandler(w http.ResponseWriter, r *http.Request) {
+	modifyTask(w, r, func(existing *Task, body []byte) (*Task, error) {
+		var updatedTask Task
+		if err := applyMergePatch(existing, body, &updatedTask); err != nil {
+			return nil, invalidBody(err)
+		}
+		return &updatedTask, nil
+	})
+}
+
+// modifyTask 更新任务的公共流程，与 modifyUser 相同地进行版本校验
+func modifyTask(w http.ResponseWriter, r *http.Request, build func(existing *Task, body []byte) (*Task, error)) {
 	params := mux.Vars(r)
 	id, err := strconv.Atoi(params["id"])
 	if err != nil {
@@ -318,63 +714,172 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查任务是否存在
-	_, err = GetTaskByID(id)
+	existingTask, err := GetTaskByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error checking task existence: %v", err)
-		if err.Error() == "task not found" {
-			sendErrorResponse(w, http.StatusNotFound, "Task not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check task existence")
-		}
+		sendError(w, err, "Failed to check task existence")
+		return
+	}
+	caller := currentUser(r)
+	if !canEditTask(caller, existingTask) {
+		sendForbidden(w)
+		return
+	}
+	if !checkIfMatch(w, r, existingTask.Version) {
+		return
+	}
+	scope, ok := parseSeriesScope(w, r)
+	if !ok {
 		return
 	}
 
-	var updatedTask Task
-	err = json.NewDecoder(r.Body).Decode(&updatedTask)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		logger.Errorf("Error decoding task update: %v", err)
+		logger.Errorf("Error reading task update: %v", err)
 		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	// 请求体无法解析时 build 返回 errInvalidBody（400），其余错误按类别映射
+	updatedTask, err := build(existingTask, body)
+	if err != nil {
+		logger.Errorf("Error building task update: %v", err)
+		sendError(w, err, "Failed to update task")
+		return
+	}
 
-	// 保留原ID
+	// 保留原ID；请求体中的版本号只用于并发校验
 	updatedTask.ID = id
+	if updatedTask.Version != 0 && updatedTask.Version != existingTask.Version {
+		sendError(w, errVersionConflict, "")
+		return
+	}
+	updatedTask.Version = existingTask.Version
+	updatedTask.SeriesID = existingTask.SeriesID
+	updatedTask.CreatedBy = existingTask.CreatedBy
+	if err := updatedTask.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+	// 重复规则属于整个系列，只能连同之后的实例一起修改
+	if existingTask.SeriesID != nil && updatedTask.Recurrence != existingTask.Recurrence && scope != seriesScopeFuture {
+		sendErrorResponse(w, http.StatusBadRequest, "Recurrence can only be changed with scope=future")
+		return
+	}
 
-	// 如果指定了新的用户ID，检查用户是否存在
-	if updatedTask.UserID != 0 {
-		_, err = GetUserByID(updatedTask.UserID)
+	// 负责人只能修改任务内容，所有者、负责人和关注者只能由所有者或拥有权限的用户修改
+	peopleChanged := updatedTask.UserID != existingTask.UserID ||
+		!sameIDs(updatedTask.AssigneeIDs, existingTask.AssigneeIDs) ||
+		!sameIDs(updatedTask.WatcherIDs, existingTask.WatcherIDs)
+	if peopleChanged && !canManageTask(caller, existingTask) {
+		sendForbidden(w)
+		return
+	}
+	// 转给他人还需要对新所有者有权限
+	if updatedTask.UserID != existingTask.UserID {
+		if !canAccessUser(caller, updatedTask.UserID, permManageAllTodos) {
+			sendForbidden(w)
+			return
+		}
+		// 检查新的用户是否存在
+		_, err = GetUserByID(r.Context(), updatedTask.UserID)
 		if err != nil {
 			logger.Errorf("Error checking user existence: %v", err)
-			if err.Error() == "user not found" {
-				sendErrorResponse(w, http.StatusNotFound, "User not found")
-			} else {
-				sendErrorResponse(w, http.StatusInternalServerError, "Failed to check user existence")
-			}
+			sendError(w, err, "Failed to check user existence")
+			return
+		}
+	}
+	if err := checkTaskLabels(updatedTask); err != nil {
+		logger.Errorf("Error checking task labels: %v", err)
+		sendError(w, err, "Failed to check task labels")
+		return
+	}
+	if err := checkTaskPeople(updatedTask); err != nil {
+		logger.Errorf("Error checking task assignees: %v", err)
+		sendError(w, err, "Failed to check task assignees")
+		return
+	}
+	if err := checkTaskParent(updatedTask); err != nil {
+		logger.Errorf("Error checking parent task: %v", err)
+		sendError(w, err, "Failed to check parent task")
+		return
+	}
+	if err := resolveTaskStatus(existingTask, updatedTask); err != nil {
+		logger.Errorf("Error resolving task status: %v", err)
+		sendError(w, err, "Failed to resolve task status")
+		return
+	}
+	// 换到其他列且未指定排序时排在列底部
+	statusChanged := updatedTask.Status != existingTask.Status || !sameProject(updatedTask.ProjectID, existingTask.ProjectID)
+	if statusChanged && updatedTask.Rank == existingTask.Rank {
+		if updatedTask.Rank, err = nextRank(updatedTask); err != nil {
+			logger.Errorf("Error ranking task: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to update task")
+			return
+		}
+	}
+	// 要求子任务先完成时，有未完成的子任务则拒绝完成
+	if updatedTask.Completed && !existingTask.Completed && updatedTask.RequireChildrenComplete {
+		open, err := hasOpenSubtasks(id)
+		if err != nil {
+			logger.Errorf("Error checking subtasks: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check subtasks")
+			return
+		}
+		if open {
+			sendError(w, errOpenSubtasks, "")
 			return
 		}
 	}
 
 	// 更新任务
-	err = UpdateTask(&updatedTask)
+	err = UpdateTask(r.Context(), updatedTask)
 	if err != nil {
 		logger.Errorf("Error updating task: %v", err)
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update task")
+		sendError(w, err, "Failed to update task")
+		return
+	}
+	if updatedTask.Status != existingTask.Status {
+		if err := recordStatusChange(updatedTask, existingTask.Status, &caller.ID); err != nil {
+			logger.Errorf("Error recording status of task %d: %v", id, err)
+		}
+	}
+	// 同步系列模板，实例完成后生成下一个实例
+	if err := applySeriesChanges(existingTask, updatedTask, scope); err != nil {
+		logger.Errorf("Error updating series of task %d: %v", id, err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update task series")
 		return
 	}
 
 	// 返回更新后的任务信息
-	resultTask, err := GetTaskByID(id)
+	resultTask, err := GetTaskByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error getting updated task: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get updated task")
 		return
 	}
+	recordAudit(r, auditActionUpdate, auditResourceTask, id, existingTask, resultTask)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(resultTask.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resultTask)
 }
 
+// removeTask 删除任务并记录审计；scope=future 时结束系列并删除之后未完成的实例
+func removeTask(r *http.Request, task *Task, scope string) error {
+	var err error
+	if scope == seriesScopeFuture && task.SeriesID != nil {
+		err = deleteSeriesFrom(r.Context(), task)
+	} else {
+		err = DeleteTask(r.Context(), task.ID)
+	}
+	if err != nil {
+		return err
+	}
+	recordAudit(r, auditActionDelete, auditResourceTask, task.ID, task, nil)
+	return nil
+}
+
 // deleteTaskHandler 删除任务
 func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
@@ -385,24 +890,28 @@ func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查任务是否存在
-	_, err = GetTaskByID(id)
+	task, err := GetTaskByID(r.Context(), id)
 	if err != nil {
 		logger.Errorf("Error checking task existence: %v", err)
-		if err.Error() == "task not found" {
-			sendErrorResponse(w, http.StatusNotFound, "Task not found")
-		} else {
-			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check task existence")
-		}
+		sendError(w, err, "Failed to check task existence")
+		return
+	}
+	if !canAccessUser(currentUser(r), task.UserID, permManageAllTodos) {
+		sendForbidden(w)
+		return
+	}
+	scope, ok := parseSeriesScope(w, r)
+	if !ok {
 		return
 	}
 
-	// 删除任务
-	err = DeleteTask(id)
-	if err != nil {
+	if err := removeTask(r, task, scope); err != nil {
 		logger.Errorf("Error deleting task: %v", err)
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete task")
 		return
 	}
+	// 附件记录已随任务解除关联，立即在后台清理内容，失败的留给定时任务
+	go cleanupOrphanAttachments()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNoContent)
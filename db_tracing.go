@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dbTracer 为原生 SQL 的仓库函数生成 span，GORM 的查询由 gormtracing 插件负责
+var dbTracer = otel.Tracer("task-scheduler/models")
+
+// startDBSpan 开始一次仓库操作的客户端 span，operation 为仓库函数名，table 为主要操作的表
+func startDBSpan(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	return dbTracer.Start(ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+		),
+	)
+}
+
+// endDBSpan 结束 span；记录不存在属于正常结果，不标记为错误
+func endDBSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, errNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
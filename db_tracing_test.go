@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDBSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+	otel.SetTracerProvider(tp)
+
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	for _, err := range []error{nil, errTaskNotFound, errors.New("connection refused")} {
+		_, span := startDBSpan(parentCtx, "GetTaskByID", "tasks")
+		endDBSpan(span, err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("recorded %d spans, want 4", len(spans))
+	}
+	wantStatus := []codes.Code{codes.Unset, codes.Unset, codes.Error}
+	for i, want := range wantStatus {
+		span := spans[i]
+		if span.Name() != "db.GetTaskByID" {
+			t.Errorf("span %d name = %q, want db.GetTaskByID", i, span.Name())
+		}
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span %d kind = %v, want client", i, span.SpanKind())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %d is not a child of the request span", i)
+		}
+		if got := span.Status().Code; got != want {
+			t.Errorf("span %d status = %v, want %v", i, got, want)
+		}
+		found := false
+		for _, attr := range span.Attributes() {
+			if attr.Key == "db.collection.name" && attr.Value.AsString() == "tasks" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("span %d is missing db.collection.name", i)
+		}
+	}
+}
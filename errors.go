@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"task-scheduler/pkg/validation"
+)
+
+// 错误类别，决定响应的 HTTP 状态码，通过 errors.Is 判断
+var (
+	errNotFound     = errors.New("not found")
+	errConflict     = errors.New("conflict")
+	errForbidden    = errors.New("forbidden")
+	errPrecondition = errors.New("precondition failed")
+	errTooLarge     = errors.New("payload too large")
+	errUnsupported  = errors.New("unsupported media type")
+	errValidation   = validation.ErrValidation
+)
+
+// mysqlDuplicateEntry MySQL 唯一约束冲突的错误号
+const mysqlDuplicateEntry = 1062
+
+// appError 带稳定错误码的业务错误
+type appError struct {
+	Kind    error  // 错误类别
+	Code    string // 机器可读、保持稳定的错误码
+	Message string // 返回给调用方的说明
+}
+
+// Error 实现 error 接口
+func (e *appError) Error() string {
+	return e.Message
+}
+
+// Is 使 errors.Is(err, errNotFound) 等类别判断成立
+func (e *appError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// 数据层返回的错误
+var (
+	errUserNotFound     = &appError{Kind: errNotFound, Code: "user_not_found", Message: "User not found"}
+	errTaskNotFound     = &appError{Kind: errNotFound, Code: "task_not_found", Message: "Task not found"}
+	errJobNotFound      = &appError{Kind: errNotFound, Code: "job_not_found", Message: "Task not found"}
+	errAPIKeyNotFound   = &appError{Kind: errNotFound, Code: "api_key_not_found", Message: "API key not found"}
+	errUsernameTaken    = &appError{Kind: errConflict, Code: "username_taken", Message: "Username is already taken"}
+	errEmailTaken       = &appError{Kind: errConflict, Code: "email_taken", Message: "Email is already registered"}
+	errDuplicate        = &appError{Kind: errConflict, Code: "duplicate", Message: "Resource already exists"}
+	errPermissionDenied = &appError{Kind: errForbidden, Code: "permission_denied", Message: "Permission denied"}
+	errVersionConflict  = &appError{Kind: errPrecondition, Code: "version_conflict", Message: "Resource has been modified, reload and try again"}
+	errInvalidBody      = &appError{Kind: errValidation, Code: "invalid_body", Message: "Invalid request body"}
+)
+
+// invalidBody 将请求体的解析错误包装为 errInvalidBody，原因保留在错误信息中供日志使用
+func invalidBody(err error) error {
+	return fmt.Errorf("%w: %v", errInvalidBody, err)
+}
+
+// isDuplicate 判断是否为唯一约束冲突：GORM 开启 TranslateError 时返回 ErrDuplicatedKey，
+// 未开启或原生 SQL 时是 MySQL 1062 错误
+func isDuplicate(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+// translateDuplicate 将用户表上的唯一约束冲突转换为对应的冲突错误，其他错误原样返回
+func translateDuplicate(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlDuplicateEntry {
+		return err
+	}
+	// 消息形如 "Duplicate entry 'x' for key 'users.email'"，只看键名，避免被冲突的值干扰
+	key := mysqlErr.Message
+	if i := strings.LastIndex(key, "for key"); i >= 0 {
+		key = key[i:]
+	}
+	switch {
+	case strings.Contains(key, "email"):
+		return errEmailTaken
+	case strings.Contains(key, "username"):
+		return errUsernameTaken
+	}
+	return errDuplicate
+}
+
+// problem RFC 7807 问题详情
+type problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    validation.Errors `json:"errors,omitempty"`
+}
+
+// writeProblem 写入 application/problem+json 响应，请求ID取自 requestIDMiddleware 设置的响应头
+func writeProblem(w http.ResponseWriter, status int, code, detail string, fields validation.Errors) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		RequestID: w.Header().Get("X-Request-ID"),
+		Errors:    fields,
+	})
+}
+
+// statusCode 没有更具体错误码时，由状态码生成通用错误码，如 404 -> not_found
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// kindStatus 错误类别对应的 HTTP 状态码
+func kindStatus(kind error) int {
+	switch kind {
+	case errNotFound:
+		return http.StatusNotFound
+	case errConflict:
+		return http.StatusConflict
+	case errForbidden:
+		return http.StatusForbidden
+	case errPrecondition:
+		return http.StatusPreconditionFailed
+	case errTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case errUnsupported:
+		return http.StatusUnsupportedMediaType
+	case errValidation:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// sendError 集中将错误映射为问题响应；无法识别的错误以 fallback 作为说明返回 500，日志由调用方记录
+func sendError(w http.ResponseWriter, err error, fallback string) {
+	var appErr *appError
+	var fields validation.Errors
+	switch {
+	case errors.As(err, &fields):
+		writeProblem(w, http.StatusBadRequest, "validation_failed", "Validation failed", fields)
+	case errors.As(err, &appErr):
+		writeProblem(w, kindStatus(appErr.Kind), appErr.Code, appErr.Message, nil)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeProblem(w, http.StatusNotFound, statusCode(http.StatusNotFound), "Resource not found", nil)
+	case isDuplicate(err):
+		sendError(w, errDuplicate, fallback)
+	default:
+		writeProblem(w, http.StatusInternalServerError, statusCode(http.StatusInternalServerError), fallback, nil)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestSendErrorDuplicateIsConflict(t *testing.T) {
+	for name, err := range map[string]error{
+		"gorm translated": gorm.ErrDuplicatedKey,
+		"mysql 1062":      &mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'a' for key 'namespaces.name'"},
+		"wrapped":         fmt.Errorf("save: %w", &mysql.MySQLError{Number: mysqlDuplicateEntry}),
+	} {
+		rec := httptest.NewRecorder()
+		sendError(rec, err, "Failed")
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusConflict)
+		}
+	}
+
+	for name, err := range map[string]error{
+		"other mysql error": &mysql.MySQLError{Number: 1213, Message: "Deadlock found"},
+		"plain error":       errors.New("connection refused"),
+	} {
+		if isDuplicate(err) {
+			t.Errorf("%s: isDuplicate() = true, want false", name)
+		}
+		rec := httptest.NewRecorder()
+		sendError(rec, err, "Failed")
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusInternalServerError)
+		}
+	}
+}
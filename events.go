@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"task-scheduler/internal/model"
+)
+
+// Event 调度器生命周期事件，Data 为发布时的数据快照
+type Event struct {
+	ID         string          `json:"id"`
+	Type       model.EventType `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// newEvent 创建带唯一ID的事件。数据在发布方的 goroutine 中立即序列化，
+// 之后发布方继续修改同一对象（如执行中的 TaskExecution）不会影响事件内容
+func newEvent(t model.EventType, data interface{}) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return Event{
+		ID:         hex.EncodeToString(buf),
+		Type:       t,
+		OccurredAt: time.Now().UTC(),
+		Data:       payload,
+	}, nil
+}
+
+// registerEventCallbacks 在 gorm 上注册回调，使所有仓库对任务和执行记录的写操作都会产生事件。
+// 事件在写操作所在的事务中写入发件箱，事务回滚时一并撤销；提交后再唤醒分发。
+// 不带主键的批量操作（如 Model(&model.Task{}).Where(...).Updates(...)）不针对单个对象，不产生事件
+func registerEventCallbacks(db *gorm.DB, d *WebhookDispatcher) error {
+	// record 在当前事务中记录事件，失败时让整个写操作回滚，避免写入成功而事件丢失
+	record := func(tx *gorm.DB, t model.EventType, data interface{}) {
+		if err := d.record(tx.Session(&gorm.Session{NewDB: true}), t, data); err != nil {
+			tx.AddError(fmt.Errorf("failed to record %s event: %w", t, err))
+		}
+	}
+
+	if err := db.Callback().Create().After("gorm:create").Register("events:after_create", func(tx *gorm.DB) {
+		if tx.Error != nil {
+			return
+		}
+		switch v := tx.Statement.Model.(type) {
+		case *model.Task:
+			if v.ID != 0 {
+				record(tx, model.EventTaskCreated, v)
+			}
+		case *model.TaskExecution:
+			if v.ID != 0 {
+				record(tx, model.EventExecutionStarted, v)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().After("gorm:update").Register("events:after_update", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			return
+		}
+		switch v := tx.Statement.Model.(type) {
+		case *model.Task:
+			if v.ID != 0 {
+				record(tx, model.EventTaskUpdated, v)
+			}
+		case *model.TaskExecution:
+			if v.ID == 0 {
+				return
+			}
+			switch v.Status {
+			case model.ExecutionStatusSuccess:
+				record(tx, model.EventExecutionSucceeded, v)
+			case model.ExecutionStatusFailed:
+				record(tx, model.EventExecutionFailed, v)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Delete().After("gorm:delete").Register("events:after_delete", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			return
+		}
+		if v, ok := tx.Statement.Model.(*model.Task); ok {
+			id := v.ID
+			if id == 0 {
+				// Delete(&model.Task{}, id) 的形式下主键只出现在查询条件中
+				id = primaryKeyCondition(tx.Statement)
+			}
+			if id != 0 {
+				record(tx, model.EventTaskDeleted, map[string]uint{"id": id})
+			}
+		}
+	}); err != nil {
+		return err
+	}
+
+	// 写操作自带的事务提交后唤醒分发；调用方显式开启的事务在这里尚未提交，
+	// 其中的事件由 RetryDue 的定期唤醒分发
+	wake := func(tx *gorm.DB) {
+		if tx.Error == nil {
+			d.wake()
+		}
+	}
+	if err := db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("events:wake_after_create", wake); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("events:wake_after_update", wake); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("events:wake_after_delete", wake)
+}
+
+// primaryKeyCondition 返回仅按单个主键删除时的主键值；带其他条件的批量删除返回 0
+func primaryKeyCondition(stmt *gorm.Statement) uint {
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return 0
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok || len(where.Exprs) != 1 {
+		return 0
+	}
+	in, ok := where.Exprs[0].(clause.IN)
+	if !ok || in.Column != clause.PrimaryColumn || len(in.Values) != 1 {
+		return 0
+	}
+	switch id := in.Values[0].(type) {
+	case uint:
+		return id
+	case int:
+		if id > 0 {
+			return uint(id)
+		}
+	}
+	return 0
+}
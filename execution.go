@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/pkg/logger"
+	"task-scheduler/pkg/tracing"
+)
+
+var tracer = otel.Tracer("task-scheduler/scheduler")
+
+// runScheduled 定时触发的执行入口，每次触发作为一条独立链路的根
+func (s *Scheduler) runScheduled(taskID uint) {
+	firedAt := time.Now()
+	ctx, span := tracer.Start(context.Background(), "scheduler.scheduled_run",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithTimestamp(firedAt),
+		trace.WithAttributes(attribute.Int64("task.id", int64(taskID))),
+	)
+	defer span.End()
+
+	task, err := repository.NewTaskRepository(s.db.WithContext(ctx)).GetById(taskID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to get task")
+		logger.Errorf("Failed to get task %d for scheduled run: %v", taskID, err)
+		return
+	}
+	if s.skippedByCalendar(ctx, task, firedAt) {
+		span.SetAttributes(attribute.Bool("execution.skipped", true))
+		logger.Infof("Skipped scheduled run of task %s (ID: %d): date is excluded by its calendar", task.Name, task.ID)
+		return
+	}
+
+	execution, err := s.createExecution(ctx, task)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create execution record")
+		logger.Errorf("Failed to create execution record for task %d: %v", taskID, err)
+		return
+	}
+
+	s.runExecution(ctx, task, execution, firedAt)
+}
+
+// skippedByCalendar 判断定时触发的日期是否在任务关联的日历中；只使用任务所在命名空间的日历，
+// 日历不存在或属于其他命名空间时照常执行
+func (s *Scheduler) skippedByCalendar(ctx context.Context, task *model.Task, at time.Time) bool {
+	if task.CalendarID == nil {
+		return false
+	}
+	calendar, err := repository.NewCalendarRepository(s.db.WithContext(ctx)).Get(task.NamespaceID, *task.CalendarID)
+	if err != nil {
+		logger.Warnf("Ignoring calendar %d of task %d: %v", *task.CalendarID, task.ID, err)
+		return false
+	}
+	return calendar.Excludes(at)
+}
+
+// secretEnv 返回任务所在命名空间的密钥对应的环境变量
+func (s *Scheduler) secretEnv(ctx context.Context, task *model.Task) ([]string, error) {
+	secrets, err := repository.NewSecretRepository(s.db.WithContext(ctx)).List(task.NamespaceID)
+	if err != nil {
+		return nil, err
+	}
+	env := make([]string, 0, len(secrets))
+	for i := range secrets {
+		env = append(env, secrets[i].Env())
+	}
+	return env, nil
+}
+
+// createExecution 创建运行中的执行记录
+func (s *Scheduler) createExecution(ctx context.Context, task *model.Task) (*model.TaskExecution, error) {
+	ctx, span := tracer.Start(ctx, "execution.create")
+	defer span.End()
+
+	execution := &model.TaskExecution{
+		TaskID:       task.ID,
+		TaskRevision: task.Revision,
+		StartTime:    time.Now(),
+		Status:       model.ExecutionStatusRunning,
+	}
+
+	execution, err := repository.NewExecutionRepository(s.db.WithContext(ctx)).Create(execution)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("execution.id", int64(execution.ID)))
+	return execution, nil
+}
+
+// runExecution 执行命令并更新执行记录。queuedAt 为定时触发或请求被接受的时间，
+// 排队 span 覆盖从那时起到命令开始执行前的读取任务、创建执行记录和等待调度
+func (s *Scheduler) runExecution(ctx context.Context, task *model.Task, execution *model.TaskExecution, queuedAt time.Time) {
+	attrs := trace.WithAttributes(
+		attribute.Int64("task.id", int64(task.ID)),
+		attribute.Int64("execution.id", int64(execution.ID)),
+	)
+
+	// 排队阶段：从提交到真正开始执行
+	_, queueSpan := tracer.Start(ctx, "execution.queue", trace.WithTimestamp(queuedAt), attrs)
+	queueSpan.End()
+
+	cmdCtx, cmdSpan := tracer.Start(ctx, "execution.command", attrs)
+	output := ""
+	env, err := s.secretEnv(cmdCtx, task)
+	if err == nil {
+		output, err = runCommand(cmdCtx, task.Command, env)
+	} else {
+		err = fmt.Errorf("failed to load namespace secrets: %w", err)
+	}
+	if err != nil {
+		cmdSpan.RecordError(err)
+		cmdSpan.SetStatus(codes.Error, err.Error())
+	}
+	cmdSpan.End()
+
+	endTime := time.Now()
+	status := model.ExecutionStatusSuccess
+	errorMsg := ""
+
+	if err != nil {
+		status = model.ExecutionStatusFailed
+		errorMsg = err.Error()
+	}
+
+	// 更新执行记录
+	recordCtx, recordSpan := tracer.Start(ctx, "execution.record", attrs)
+	defer recordSpan.End()
+
+	execution.EndTime = &endTime
+	execution.Status = status
+	execution.Output = output
+	execution.Error = errorMsg
+
+	if _, err := repository.NewExecutionRepository(s.db.WithContext(recordCtx)).Update(execution); err != nil {
+		recordSpan.RecordError(err)
+		recordSpan.SetStatus(codes.Error, err.Error())
+		logger.Errorf("Failed to update execution record %d: %v", execution.ID, err)
+	}
+}
+
+// runCommand 通过 shell 执行命令，env 为额外的环境变量；当前链路上下文以 TRACEPARENT 环境变量传给子进程
+func runCommand(ctx context.Context, command string, env []string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(append(os.Environ(), env...), tracing.TraceEnv(ctx)...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("command failed: %w", err)
+	}
+	return string(output), nil
+}
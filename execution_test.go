@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRunCommandPropagatesTraceContext(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "execution.command")
+	defer span.End()
+
+	output, err := runCommand(ctx, `printf %s "$TRACEPARENT"`, nil)
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+	sc := span.SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if output != want {
+		t.Errorf("child TRACEPARENT = %q, want %q", output, want)
+	}
+}
+
+func TestRunCommandFailure(t *testing.T) {
+	output, err := runCommand(context.Background(), "echo partial; exit 3", nil)
+	if err == nil {
+		t.Fatal("runCommand() error = nil, want failure")
+	}
+	if output != "partial\n" {
+		t.Errorf("runCommand() output = %q, want %q", output, "partial\n")
+	}
+}
+
+func TestRunCommandPassesEnv(t *testing.T) {
+	output, err := runCommand(context.Background(), `printf %s "$API_TOKEN"`, []string{"API_TOKEN=s3cret"})
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+	if output != "s3cret" {
+		t.Errorf("child API_TOKEN = %q, want %q", output, "s3cret")
+	}
+}
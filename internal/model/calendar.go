@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"task-scheduler/pkg/validation"
+)
+
+const (
+	maxCalendarNameLength = 100
+	maxCalendarDates      = 366
+	calendarDateLayout    = "2006-01-02"
+)
+
+// Calendar 命名空间内的节假日日历，任务关联日历后在其中的日期跳过定时执行
+type Calendar struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	NamespaceID   uint      `gorm:"not null;uniqueIndex:idx_calendar_namespace_name" json:"namespace_id"`
+	Name          string    `gorm:"size:100;not null;uniqueIndex:idx_calendar_namespace_name" json:"name"`
+	Description   string    `gorm:"size:500" json:"description"`
+	ExcludedDates string    `gorm:"type:text" json:"excluded_dates"` // 逗号分隔的 YYYY-MM-DD
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// dates 返回日历中的日期
+func (c *Calendar) dates() []string {
+	var dates []string
+	for _, d := range strings.Split(c.ExcludedDates, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+// Validate 校验日历字段
+func (c *Calendar) Validate() error {
+	var v validation.Validator
+	v.String("name", c.Name, validation.Required, validation.MaxLength(maxCalendarNameLength))
+	v.String("description", c.Description, validation.MaxLength(500))
+	dates := c.dates()
+	v.Check(len(dates) <= maxCalendarDates, "excluded_dates", validation.CodeTooLong, fmt.Sprintf("must contain at most %d dates", maxCalendarDates))
+	for _, d := range dates {
+		if _, err := time.Parse(calendarDateLayout, d); err != nil {
+			v.Add("excluded_dates", validation.CodeInvalid, "must be comma-separated dates in YYYY-MM-DD format")
+			break
+		}
+	}
+	return v.Err()
+}
+
+// Excludes 判断 t 所在的日期（按 t 的时区）是否在日历中
+func (c *Calendar) Excludes(t time.Time) bool {
+	day := t.Format(calendarDateLayout)
+	for _, d := range c.dates() {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
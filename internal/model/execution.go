@@ -28,6 +28,12 @@ type TaskExecution struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 	
+	// 执行时任务定义的修订号
+	TaskRevision int `json:"task_revision"`
+
+	// 超时告警的时间，为空表示尚未告警
+	SLAAlertedAt *time.Time `json:"-"`
+	
 	// 关联
 	Task *Task `gorm:"foreignKey:TaskID" json:"task,omitempty"`
 }
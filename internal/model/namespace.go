@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/pkg/validation"
+)
+
+// DefaultNamespaceName 未指定命名空间的任务归属的默认命名空间
+const DefaultNamespaceName = "default"
+
+// NamespaceRole 命名空间成员角色
+type NamespaceRole string
+
+const (
+	NamespaceRoleAdmin    NamespaceRole = "admin"    // 管理命名空间、成员及其中的任务
+	NamespaceRoleOperator NamespaceRole = "operator" // 启动、停止、立即执行任务
+	NamespaceRoleViewer   NamespaceRole = "viewer"   // 只读查看任务与执行记录
+)
+
+// Valid 判断角色是否合法
+func (r NamespaceRole) Valid() bool {
+	switch r {
+	case NamespaceRoleAdmin, NamespaceRoleOperator, NamespaceRoleViewer:
+		return true
+	}
+	return false
+}
+
+// Namespace 团队命名空间，定时任务归属于命名空间
+type Namespace struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"size:100;not null;unique" json:"name"`
+	Description string         `gorm:"size:500" json:"description"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Validate 校验命名空间字段
+func (n *Namespace) Validate() error {
+	var v validation.Validator
+	v.String("name", n.Name, validation.Required, validation.MaxLength(100))
+	v.String("description", n.Description, validation.MaxLength(500))
+	return v.Err()
+}
+
+// NamespaceMember 用户在命名空间中的成员身份
+type NamespaceMember struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	NamespaceID uint          `gorm:"not null;uniqueIndex:idx_namespace_member" json:"namespace_id"`
+	UserID      uint          `gorm:"not null;uniqueIndex:idx_namespace_member;index" json:"user_id"`
+	Role        NamespaceRole `gorm:"size:20;not null" json:"role"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+)
+
+// RetentionPolicy 执行记录保留策略，TaskID 为空表示全局策略。
+// 各项为空表示未设置：任务级策略沿用全局策略的值，全局策略沿用默认值；
+// 各项为 0 都表示不限，因此任务级策略可以显式设置 0，在全局策略有限制时表示不限
+type RetentionPolicy struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	TaskID         *uint     `gorm:"uniqueIndex" json:"task_id,omitempty"`
+	MaxAgeDays     *int      `json:"max_age_days"`     // 超过天数的执行记录将被软删除，0 表示不限
+	KeepLast       *int      `json:"keep_last"`        // 每个任务只保留最近N条，0 表示不限
+	PurgeAfterDays *int      `json:"purge_after_days"` // 软删除后经过的宽限天数，之后物理删除，0 表示不物理删除
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Merge 用任务级策略中已设置的字段覆盖全局策略
+func (p RetentionPolicy) Merge(override *RetentionPolicy) RetentionPolicy {
+	if override == nil {
+		return p
+	}
+	if override.MaxAgeDays != nil {
+		p.MaxAgeDays = override.MaxAgeDays
+	}
+	if override.KeepLast != nil {
+		p.KeepLast = override.KeepLast
+	}
+	if override.PurgeAfterDays != nil {
+		p.PurgeAfterDays = override.PurgeAfterDays
+	}
+	p.TaskID = override.TaskID
+	return p
+}
+
+// Limits 返回生效的各项取值，未设置的按 0（不限）处理
+func (p RetentionPolicy) Limits() (maxAgeDays, keepLast, purgeAfterDays int) {
+	return intValue(p.MaxAgeDays), intValue(p.KeepLast), intValue(p.PurgeAfterDays)
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
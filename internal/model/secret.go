@@ -0,0 +1,40 @@
+package model
+
+import (
+	"regexp"
+	"time"
+
+	"task-scheduler/pkg/validation"
+)
+
+// secretNamePattern 密钥名称即传给命令的环境变量名
+var secretNamePattern = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+const (
+	maxSecretNameLength  = 100
+	maxSecretValueLength = 4096
+)
+
+// Secret 命名空间内的密钥，执行该命名空间的任务时以环境变量传给命令；值只写不读
+type Secret struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	NamespaceID uint      `gorm:"not null;uniqueIndex:idx_secret_namespace_name" json:"namespace_id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex:idx_secret_namespace_name" json:"name"`
+	Value       string    `gorm:"type:text;not null" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate 校验密钥字段
+func (s *Secret) Validate() error {
+	var v validation.Validator
+	v.String("name", s.Name, validation.Required, validation.MaxLength(maxSecretNameLength),
+		validation.Matches(secretNamePattern, "upper-case letters, digits and '_'"))
+	v.String("value", s.Value, validation.Required, validation.MaxLength(maxSecretValueLength))
+	return v.Err()
+}
+
+// Env 返回传给命令的环境变量
+func (s *Secret) Env() string {
+	return s.Name + "=" + s.Value
+}
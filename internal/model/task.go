@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"task-scheduler/pkg/validation"
 )
 
 // TaskStatus 任务状态
@@ -17,15 +19,23 @@ const (
 // Task 表示一个定时任务
 type Task struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"size:100;not null;unique" json:"name"`
+	NamespaceID uint           `gorm:"not null;uniqueIndex:idx_task_namespace_name" json:"namespace_id"`
+	Name        string         `gorm:"size:100;not null;uniqueIndex:idx_task_namespace_name" json:"name"` // 同一命名空间内唯一
 	Description string         `gorm:"size:500" json:"description"`
 	CronExpr    string         `gorm:"not null" json:"cron_expr"` // cron表达式
 	Command     string         `gorm:"not null" json:"command"`   // 要执行的命令
 	IsEnabled   bool           `gorm:"default:true" json:"is_enabled"`
 	Status      TaskStatus     `gorm:"default:stopped" json:"status"`
+	MaxDuration int            `json:"max_duration"`               // 预期最长执行时间（秒），0 表示不限
+	SLADeadline string         `gorm:"size:5" json:"sla_deadline"` // 每日须在该时间（HH:MM）前成功执行一次
+	CalendarID  *uint          `json:"calendar_id"`                // 同一命名空间的节假日日历，其中的日期跳过定时执行
+	Revision    int            `gorm:"default:0" json:"revision"`  // 当前定义对应的修订号
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 最近一次已告警的 SLA 截止时间，用于跨重启去重
+	SLAAlertedDeadline *time.Time `json:"-"`
 }
 
 // BeforeCreate 创建前的钩子
@@ -35,3 +45,40 @@ func (t *Task) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// 字段长度上限，与表结构保持一致
+const (
+	maxTaskNameLength        = 100
+	maxTaskDescriptionLength = 500
+	maxTaskCommandLength     = 4096
+)
+
+// Validate 校验任务定义，返回 validation.Errors 列出所有不合法的字段
+func (t *Task) Validate() error {
+	var v validation.Validator
+	v.String("name", t.Name, validation.Required, validation.MaxLength(maxTaskNameLength))
+	v.String("description", t.Description, validation.MaxLength(maxTaskDescriptionLength))
+	v.String("cron_expr", t.CronExpr, validation.Required, validation.Cron)
+	v.String("command", t.Command, validation.Required, validation.MaxLength(maxTaskCommandLength))
+	v.Check(t.MaxDuration >= 0, "max_duration", validation.CodeOutOfRange, "must not be negative")
+	v.String("sla_deadline", t.SLADeadline, validation.TimeOfDay)
+	return v.Err()
+}
+
+// BeforeSave 保存前校验任务定义，所有写入路径都会经过这里
+func (t *Task) BeforeSave(tx *gorm.DB) error {
+	return t.Validate()
+}
+
+// SLADeadlineOn 返回指定日期的 SLA 截止时间，未配置时返回 false
+func (t *Task) SLADeadlineOn(day time.Time) (time.Time, bool) {
+	if t.SLADeadline == "" {
+		return time.Time{}, false
+	}
+	hm, err := time.Parse("15:04", t.SLADeadline)
+	if err != nil {
+		return time.Time{}, false
+	}
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hm.Hour(), hm.Minute(), 0, 0, day.Location()), true
+}
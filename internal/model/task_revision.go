@@ -0,0 +1,105 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRevisionImmutable 修订记录写入后不允许修改或删除
+var ErrRevisionImmutable = errors.New("task revisions are immutable")
+
+// TaskRevision 任务定义的一个不可变版本
+type TaskRevision struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TaskID      uint      `gorm:"not null;uniqueIndex:idx_task_revision" json:"task_id"`
+	Revision    int       `gorm:"not null;uniqueIndex:idx_task_revision" json:"revision"`
+	NamespaceID uint      `json:"namespace_id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"size:500" json:"description"`
+	CronExpr    string    `gorm:"not null" json:"cron_expr"`
+	Command     string    `gorm:"not null" json:"command"`
+	IsEnabled   bool      `json:"is_enabled"`
+	MaxDuration int       `json:"max_duration"`
+	SLADeadline string    `gorm:"size:5" json:"sla_deadline"`
+	AuthorID    *uint     `json:"author_id,omitempty"` // 为空表示系统变更
+	AuthorName  string    `gorm:"size:100" json:"author_name"`
+	Message     string    `gorm:"size:200" json:"message"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RevisionChange 两个修订之间单个字段的差异
+type RevisionChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// NewTaskRevision 根据任务当前定义生成修订
+func NewTaskRevision(task *Task, revision int) *TaskRevision {
+	return &TaskRevision{
+		TaskID:      task.ID,
+		Revision:    revision,
+		NamespaceID: task.NamespaceID,
+		Name:        task.Name,
+		Description: task.Description,
+		CronExpr:    task.CronExpr,
+		Command:     task.Command,
+		IsEnabled:   task.IsEnabled,
+		MaxDuration: task.MaxDuration,
+		SLADeadline: task.SLADeadline,
+	}
+}
+
+// definition 返回参与比较的定义字段
+func (r *TaskRevision) definition() map[string]interface{} {
+	return map[string]interface{}{
+		"namespace_id": r.NamespaceID,
+		"name":         r.Name,
+		"description":  r.Description,
+		"cron_expr":    r.CronExpr,
+		"command":      r.Command,
+		"is_enabled":   r.IsEnabled,
+		"max_duration": r.MaxDuration,
+		"sla_deadline": r.SLADeadline,
+	}
+}
+
+// Diff 返回从当前修订到 other 发生变化的字段
+func (r *TaskRevision) Diff(other *TaskRevision) map[string]RevisionChange {
+	from, to := r.definition(), other.definition()
+	changes := make(map[string]RevisionChange)
+	for k, v := range from {
+		if to[k] != v {
+			changes[k] = RevisionChange{From: v, To: to[k]}
+		}
+	}
+	return changes
+}
+
+// Matches 判断任务当前定义是否与修订一致
+func (r *TaskRevision) Matches(task *Task) bool {
+	return len(r.Diff(NewTaskRevision(task, r.Revision))) == 0
+}
+
+// ApplyTo 将修订中的定义写回任务（不修改运行状态）
+func (r *TaskRevision) ApplyTo(task *Task) {
+	task.NamespaceID = r.NamespaceID
+	task.Name = r.Name
+	task.Description = r.Description
+	task.CronExpr = r.CronExpr
+	task.Command = r.Command
+	task.IsEnabled = r.IsEnabled
+	task.MaxDuration = r.MaxDuration
+	task.SLADeadline = r.SLADeadline
+}
+
+// BeforeUpdate 禁止修改已有修订
+func (r *TaskRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
+
+// BeforeDelete 禁止删除修订
+func (r *TaskRevision) BeforeDelete(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
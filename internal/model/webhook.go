@@ -0,0 +1,95 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventType 调度器生命周期事件类型
+type EventType string
+
+const (
+	EventTaskCreated        EventType = "task.created"
+	EventTaskUpdated        EventType = "task.updated"
+	EventTaskDeleted        EventType = "task.deleted"
+	EventTaskStarted        EventType = "task.started"
+	EventTaskStopped        EventType = "task.stopped"
+	EventExecutionStarted   EventType = "execution.started"
+	EventExecutionSucceeded EventType = "execution.succeeded"
+	EventExecutionFailed    EventType = "execution.failed"
+
+	// SLA 告警
+	EventSLADurationBreached EventType = "sla.duration_breached"
+	EventSLADeadlineMissed   EventType = "sla.deadline_missed"
+)
+
+// DeliveryStatus 投递状态
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending DeliveryStatus = "pending"
+	DeliveryStatusSuccess DeliveryStatus = "success"
+	DeliveryStatusFailed  DeliveryStatus = "failed"
+)
+
+// WebhookSubscription 表示一个 webhook 订阅
+type WebhookSubscription struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	URL         string         `gorm:"size:500;not null" json:"url"`
+	Secret      string         `gorm:"size:200;not null" json:"-"`  // 签名密钥
+	EventTypes  string         `gorm:"size:500" json:"event_types"` // 逗号分隔，空表示订阅全部事件
+	IsEnabled   bool           `gorm:"default:true" json:"is_enabled"`
+	MaxAttempts int            `gorm:"default:5" json:"max_attempts"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Accepts 判断订阅是否关心指定事件
+func (s *WebhookSubscription) Accepts(t EventType) bool {
+	if strings.TrimSpace(s.EventTypes) == "" {
+		return true
+	}
+	for _, et := range strings.Split(s.EventTypes, ",") {
+		if EventType(strings.TrimSpace(et)) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery 记录一次事件投递（含重试状态）
+type WebhookDelivery struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint           `gorm:"not null;index" json:"subscription_id"`
+	EventID        string         `gorm:"size:64;not null;index" json:"event_id"`
+	EventType      EventType      `gorm:"size:50;not null" json:"event_type"`
+	Payload        string         `gorm:"type:text" json:"payload"`
+	Status         DeliveryStatus `gorm:"default:pending;index" json:"status"`
+	Attempts       int            `json:"attempts"`
+	ResponseCode   int            `json:"response_code,omitempty"`
+	LastError      string         `gorm:"type:text" json:"last_error,omitempty"`
+	NextRetryAt    *time.Time     `gorm:"index" json:"next_retry_at,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	ReplayOf       *uint          `json:"replay_of,omitempty"` // 重放来源投递ID
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 关联
+	Subscription *WebhookSubscription `gorm:"foreignKey:SubscriptionID" json:"subscription,omitempty"`
+}
+
+// WebhookEvent 待分发的事件（发件箱）。与产生事件的写操作在同一事务中写入，事务回滚时一并撤销；
+// 提交后由投递器为每个匹配的订阅创建投递记录，并记下分发时间
+type WebhookEvent struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	EventID      string     `gorm:"size:64;not null;uniqueIndex" json:"event_id"`
+	EventType    EventType  `gorm:"size:50;not null" json:"event_type"`
+	Payload      string     `gorm:"type:text" json:"payload"` // 序列化后的完整事件
+	DispatchedAt *time.Time `gorm:"index" json:"dispatched_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
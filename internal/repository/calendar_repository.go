@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// CalendarRepository 命名空间日历仓库，所有查询都限定在命名空间内
+type CalendarRepository struct {
+	db *gorm.DB
+}
+
+// NewCalendarRepository 创建日历仓库
+func NewCalendarRepository(db *gorm.DB) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+// List 获取命名空间中的日历（按名称排序）
+func (r *CalendarRepository) List(namespaceID uint) ([]model.Calendar, error) {
+	var calendars []model.Calendar
+	err := r.db.Where("namespace_id = ?", namespaceID).Order("name").Find(&calendars).Error
+	return calendars, err
+}
+
+// Get 获取命名空间中的日历，不属于该命名空间时返回 gorm.ErrRecordNotFound
+func (r *CalendarRepository) Get(namespaceID, id uint) (*model.Calendar, error) {
+	var calendar model.Calendar
+	if err := r.db.Where("namespace_id = ?", namespaceID).First(&calendar, id).Error; err != nil {
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+// Create 创建日历
+func (r *CalendarRepository) Create(calendar *model.Calendar) (*model.Calendar, error) {
+	if err := r.db.Create(calendar).Error; err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// Update 更新日历
+func (r *CalendarRepository) Update(calendar *model.Calendar) (*model.Calendar, error) {
+	if err := r.db.Save(calendar).Error; err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// Delete 删除命名空间中的日历，并解除任务与它的关联
+func (r *CalendarRepository) Delete(namespaceID, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("namespace_id = ?", namespaceID).Delete(&model.Calendar{}, id)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&model.Task{}).
+			Where("namespace_id = ? AND calendar_id = ?", namespaceID, id).
+			UpdateColumn("calendar_id", nil).Error
+	})
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"task-scheduler/internal/model"
+)
+
+// ErrNamespaceNotEmpty 命名空间下仍有任务
+var ErrNamespaceNotEmpty = errors.New("namespace still contains tasks")
+
+// NamespaceRepository 命名空间与成员仓库
+type NamespaceRepository struct {
+	db *gorm.DB
+}
+
+// NewNamespaceRepository 创建命名空间仓库
+func NewNamespaceRepository(db *gorm.DB) *NamespaceRepository {
+	return &NamespaceRepository{db: db}
+}
+
+// Create 创建命名空间，并将创建者加为管理员
+func (r *NamespaceRepository) Create(ns *model.Namespace, ownerID uint) (*model.Namespace, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ns).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.NamespaceMember{
+			NamespaceID: ns.ID,
+			UserID:      ownerID,
+			Role:        model.NamespaceRoleAdmin,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// GetById 根据ID获取命名空间
+func (r *NamespaceRepository) GetById(id uint) (*model.Namespace, error) {
+	var ns model.Namespace
+	if err := r.db.First(&ns, id).Error; err != nil {
+		return nil, err
+	}
+	return &ns, nil
+}
+
+// List 获取所有命名空间
+func (r *NamespaceRepository) List() ([]model.Namespace, error) {
+	var namespaces []model.Namespace
+	err := r.db.Order("id").Find(&namespaces).Error
+	return namespaces, err
+}
+
+// ListForUser 获取用户所属的命名空间
+func (r *NamespaceRepository) ListForUser(userID uint) ([]model.Namespace, error) {
+	var namespaces []model.Namespace
+	err := r.db.
+		Where("id IN (?)", r.db.Model(&model.NamespaceMember{}).Select("namespace_id").Where("user_id = ?", userID)).
+		Order("id").
+		Find(&namespaces).Error
+	return namespaces, err
+}
+
+// Update 更新命名空间
+func (r *NamespaceRepository) Update(ns *model.Namespace) (*model.Namespace, error) {
+	if err := r.db.Save(ns).Error; err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// Delete 删除命名空间及其成员、密钥和日历，命名空间下仍有任务时返回错误
+func (r *NamespaceRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Task{}).Where("namespace_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrNamespaceNotEmpty
+		}
+		for _, m := range []interface{}{&model.NamespaceMember{}, &model.Secret{}, &model.Calendar{}} {
+			if err := tx.Where("namespace_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.Namespace{}, id).Error
+	})
+}
+
+// GetMember 获取用户在命名空间中的成员身份，不是成员时返回 nil
+func (r *NamespaceRepository) GetMember(namespaceID, userID uint) (*model.NamespaceMember, error) {
+	var m model.NamespaceMember
+	err := r.db.Where("namespace_id = ? AND user_id = ?", namespaceID, userID).Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListMembers 获取命名空间的所有成员
+func (r *NamespaceRepository) ListMembers(namespaceID uint) ([]model.NamespaceMember, error) {
+	var members []model.NamespaceMember
+	err := r.db.Where("namespace_id = ?", namespaceID).Order("id").Find(&members).Error
+	return members, err
+}
+
+// SaveMember 添加成员或修改成员角色
+func (r *NamespaceRepository) SaveMember(m *model.NamespaceMember) (*model.NamespaceMember, error) {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "namespace_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(m).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetMember(m.NamespaceID, m.UserID)
+}
+
+// RemoveMember 移除成员
+func (r *NamespaceRepository) RemoveMember(namespaceID, userID uint) error {
+	return r.db.Where("namespace_id = ? AND user_id = ?", namespaceID, userID).Delete(&model.NamespaceMember{}).Error
+}
+
+// ListTasks 获取指定命名空间中的定时任务
+func (r *NamespaceRepository) ListTasks(namespaceIDs []uint) ([]model.Task, error) {
+	var tasks []model.Task
+	if len(namespaceIDs) == 0 {
+		return tasks, nil
+	}
+	err := r.db.Where("namespace_id IN ?", namespaceIDs).Order("namespace_id, name").Find(&tasks).Error
+	return tasks, err
+}
+
+// EnsureDefault 确保默认命名空间存在，并将未归属的任务移入其中
+func (r *NamespaceRepository) EnsureDefault() (*model.Namespace, error) {
+	ns := model.Namespace{Name: model.DefaultNamespaceName}
+	err := r.db.Where("name = ?", ns.Name).FirstOrCreate(&ns).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Model(&model.Task{}).Where("namespace_id = 0").UpdateColumn("namespace_id", ns.ID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ns, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// RetentionRepository 保留策略与执行记录清理仓库
+type RetentionRepository struct {
+	db *gorm.DB
+}
+
+// NewRetentionRepository 创建保留策略仓库
+func NewRetentionRepository(db *gorm.DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// GetGlobal 获取全局策略，未配置时返回 nil
+func (r *RetentionRepository) GetGlobal() (*model.RetentionPolicy, error) {
+	var p model.RetentionPolicy
+	err := r.db.Where("task_id IS NULL").First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetForTask 获取任务级策略，未配置时返回 nil
+func (r *RetentionRepository) GetForTask(taskID uint) (*model.RetentionPolicy, error) {
+	var p model.RetentionPolicy
+	err := r.db.Where("task_id = ?", taskID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListTaskPolicies 获取所有任务级策略
+func (r *RetentionRepository) ListTaskPolicies() ([]model.RetentionPolicy, error) {
+	var policies []model.RetentionPolicy
+	err := r.db.Where("task_id IS NOT NULL").Order("task_id").Find(&policies).Error
+	return policies, err
+}
+
+// Save 创建或更新策略（按 TaskID 定位）
+func (r *RetentionRepository) Save(p *model.RetentionPolicy) (*model.RetentionPolicy, error) {
+	var existing *model.RetentionPolicy
+	var err error
+	if p.TaskID == nil {
+		existing, err = r.GetGlobal()
+	} else {
+		existing, err = r.GetForTask(*p.TaskID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		p.ID = existing.ID
+		p.CreatedAt = existing.CreatedAt
+	}
+
+	if err := r.db.Save(p).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeleteForTask 删除任务级策略
+func (r *RetentionRepository) DeleteForTask(taskID uint) error {
+	return r.db.Where("task_id = ?", taskID).Delete(&model.RetentionPolicy{}).Error
+}
+
+// ListExecutionTaskIDs 获取存在执行记录（含已软删除）的任务ID
+func (r *RetentionRepository) ListExecutionTaskIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&model.TaskExecution{}).Distinct().Pluck("task_id", &ids).Error
+	return ids, err
+}
+
+// SoftDeleteOlderThan 软删除任务在指定时间之前开始的已结束执行记录
+func (r *RetentionRepository) SoftDeleteOlderThan(taskID uint, cutoff time.Time) (int64, error) {
+	result := r.db.
+		Where("task_id = ? AND start_time < ? AND status <> ?", taskID, cutoff, model.ExecutionStatusRunning).
+		Delete(&model.TaskExecution{})
+	return result.RowsAffected, result.Error
+}
+
+// SoftDeleteBeyondLast 软删除任务最近 keep 条之外的已结束执行记录
+func (r *RetentionRepository) SoftDeleteBeyondLast(taskID uint, keep int) (int64, error) {
+	// 找到第 keep 新的记录作为分界点
+	var boundary model.TaskExecution
+	err := r.db.Where("task_id = ?", taskID).
+		Order("start_time DESC, id DESC").
+		Offset(keep - 1).
+		Limit(1).
+		Take(&boundary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result := r.db.
+		Where("task_id = ? AND status <> ?", taskID, model.ExecutionStatusRunning).
+		Where("start_time < ? OR (start_time = ? AND id < ?)", boundary.StartTime, boundary.StartTime, boundary.ID).
+		Delete(&model.TaskExecution{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeSoftDeleted 物理删除软删除时间早于 cutoff 的执行记录，返回删除行数和释放的日志字节数
+func (r *RetentionRepository) PurgeSoftDeleted(taskID uint, cutoff time.Time) (int64, int64, error) {
+	var rows, bytes int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		scope := func() *gorm.DB {
+			return tx.Unscoped().Model(&model.TaskExecution{}).
+				Where("task_id = ? AND deleted_at IS NOT NULL AND deleted_at < ?", taskID, cutoff)
+		}
+
+		if err := scope().
+			Select("COALESCE(SUM(COALESCE(LENGTH(output), 0) + COALESCE(LENGTH(error), 0)), 0)").
+			Scan(&bytes).Error; err != nil {
+			return err
+		}
+
+		result := scope().Delete(&model.TaskExecution{})
+		rows = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return rows, bytes, nil
+}
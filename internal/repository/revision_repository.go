@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// RevisionRepository 任务定义修订仓库，只支持追加和查询
+type RevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewRevisionRepository 创建修订仓库
+func NewRevisionRepository(db *gorm.DB) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+// Create 追加修订
+func (r *RevisionRepository) Create(rev *model.TaskRevision) (*model.TaskRevision, error) {
+	if err := r.db.Create(rev).Error; err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Get 获取任务的指定修订
+func (r *RevisionRepository) Get(taskID uint, revision int) (*model.TaskRevision, error) {
+	var rev model.TaskRevision
+	err := r.db.Where("task_id = ? AND revision = ?", taskID, revision).Take(&rev).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// Latest 获取任务的最新修订，没有修订时返回 nil
+func (r *RevisionRepository) Latest(taskID uint) (*model.TaskRevision, error) {
+	var rev model.TaskRevision
+	err := r.db.Where("task_id = ?", taskID).Order("revision DESC").Take(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// ListByTask 获取任务的所有修订（按修订号倒序）
+func (r *RevisionRepository) ListByTask(taskID uint) ([]model.TaskRevision, error) {
+	var revs []model.TaskRevision
+	err := r.db.Where("task_id = ?", taskID).Order("revision DESC").Find(&revs).Error
+	return revs, err
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// SecretRepository 命名空间密钥仓库，所有查询都限定在命名空间内
+type SecretRepository struct {
+	db *gorm.DB
+}
+
+// NewSecretRepository 创建密钥仓库
+func NewSecretRepository(db *gorm.DB) *SecretRepository {
+	return &SecretRepository{db: db}
+}
+
+// List 获取命名空间中的密钥（按名称排序）
+func (r *SecretRepository) List(namespaceID uint) ([]model.Secret, error) {
+	var secrets []model.Secret
+	err := r.db.Where("namespace_id = ?", namespaceID).Order("name").Find(&secrets).Error
+	return secrets, err
+}
+
+// Get 获取命名空间中的密钥，不属于该命名空间时返回 gorm.ErrRecordNotFound
+func (r *SecretRepository) Get(namespaceID, id uint) (*model.Secret, error) {
+	var secret model.Secret
+	if err := r.db.Where("namespace_id = ?", namespaceID).First(&secret, id).Error; err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// Create 创建密钥
+func (r *SecretRepository) Create(secret *model.Secret) (*model.Secret, error) {
+	if err := r.db.Create(secret).Error; err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Update 更新密钥
+func (r *SecretRepository) Update(secret *model.Secret) (*model.Secret, error) {
+	if err := r.db.Save(secret).Error; err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Delete 删除命名空间中的密钥
+func (r *SecretRepository) Delete(namespaceID, id uint) error {
+	return r.db.Where("namespace_id = ?", namespaceID).Delete(&model.Secret{}, id).Error
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// StatsRepository 执行统计查询仓库
+type StatsRepository struct {
+	db *gorm.DB
+}
+
+// NewStatsRepository 创建统计查询仓库
+func NewStatsRepository(db *gorm.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// ListExecutionsBetween 获取任务在时间窗口内开始的执行记录（按开始时间升序，不含输出内容）
+func (r *StatsRepository) ListExecutionsBetween(taskID uint, from, to time.Time) ([]model.TaskExecution, error) {
+	var executions []model.TaskExecution
+	err := r.db.
+		Select("id", "task_id", "start_time", "end_time", "status").
+		Where("task_id = ? AND start_time >= ? AND start_time < ?", taskID, from, to).
+		Order("start_time, id").
+		Find(&executions).Error
+	return executions, err
+}
+
+// ListRunningStartedBefore 获取任务中在指定时间前开始、仍在运行且尚未告警的执行记录
+func (r *StatsRepository) ListRunningStartedBefore(taskID uint, before time.Time) ([]model.TaskExecution, error) {
+	var executions []model.TaskExecution
+	err := r.db.
+		Select("id", "task_id", "start_time", "end_time", "status").
+		Where("task_id = ? AND status = ? AND start_time < ? AND sla_alerted_at IS NULL", taskID, model.ExecutionStatusRunning, before).
+		Find(&executions).Error
+	return executions, err
+}
+
+// ListFinishedSince 获取任务中在指定时间后结束且尚未告警的执行记录
+func (r *StatsRepository) ListFinishedSince(taskID uint, since time.Time) ([]model.TaskExecution, error) {
+	var executions []model.TaskExecution
+	err := r.db.
+		Select("id", "task_id", "start_time", "end_time", "status").
+		Where("task_id = ? AND end_time IS NOT NULL AND end_time >= ? AND sla_alerted_at IS NULL", taskID, since).
+		Find(&executions).Error
+	return executions, err
+}
+
+// HasSuccessBetween 判断任务在时间窗口内是否有成功结束的执行
+func (r *StatsRepository) HasSuccessBetween(taskID uint, from, to time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.TaskExecution{}).
+		Where("task_id = ? AND status = ? AND end_time >= ? AND end_time <= ?", taskID, model.ExecutionStatusSuccess, from, to).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ClaimDurationAlert 标记执行的超时告警，已被标记时返回 false，多个实例同时检查时只有一个会告警
+func (r *StatsRepository) ClaimDurationAlert(executionID uint, at time.Time) (bool, error) {
+	result := r.db.Model(&model.TaskExecution{}).
+		Where("id = ? AND sla_alerted_at IS NULL", executionID).
+		UpdateColumn("sla_alerted_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClaimDeadlineAlert 标记任务在该截止时间的告警，同一截止时间已告警过时返回 false
+func (r *StatsRepository) ClaimDeadlineAlert(taskID uint, deadline time.Time) (bool, error) {
+	result := r.db.Model(&model.Task{}).
+		Where("id = ? AND (sla_alerted_deadline IS NULL OR sla_alerted_deadline < ?)", taskID, deadline).
+		UpdateColumn("sla_alerted_deadline", deadline)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListRecentExecutions 获取任务最近的执行记录（按开始时间倒序）
+func (r *StatsRepository) ListRecentExecutions(taskID uint, limit int) ([]model.TaskExecution, error) {
+	var executions []model.TaskExecution
+	err := r.db.
+		Where("task_id = ?", taskID).
+		Order("start_time DESC, id DESC").
+		Limit(limit).
+		Find(&executions).Error
+	return executions, err
+}
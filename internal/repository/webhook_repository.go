@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// WebhookRepository webhook 订阅仓库
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository 创建 webhook 订阅仓库
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Create 创建订阅
+func (r *WebhookRepository) Create(sub *model.WebhookSubscription) (*model.WebhookSubscription, error) {
+	if err := r.db.Create(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetById 根据ID获取订阅
+func (r *WebhookRepository) GetById(id uint) (*model.WebhookSubscription, error) {
+	var sub model.WebhookSubscription
+	if err := r.db.First(&sub, id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// List 获取所有订阅
+func (r *WebhookRepository) List() ([]model.WebhookSubscription, error) {
+	var subs []model.WebhookSubscription
+	err := r.db.Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// ListEnabled 获取所有启用的订阅
+func (r *WebhookRepository) ListEnabled() ([]model.WebhookSubscription, error) {
+	var subs []model.WebhookSubscription
+	err := r.db.Where("is_enabled = ?", true).Find(&subs).Error
+	return subs, err
+}
+
+// Update 更新订阅
+func (r *WebhookRepository) Update(sub *model.WebhookSubscription) (*model.WebhookSubscription, error) {
+	if err := r.db.Save(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Delete 删除订阅
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.db.Delete(&model.WebhookSubscription{}, id).Error
+}
+
+// DeliveryRepository webhook 投递记录仓库
+type DeliveryRepository struct {
+	db *gorm.DB
+}
+
+// NewDeliveryRepository 创建投递记录仓库
+func NewDeliveryRepository(db *gorm.DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+// Create 创建投递记录
+func (r *DeliveryRepository) Create(d *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	if err := r.db.Create(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetById 根据ID获取投递记录
+func (r *DeliveryRepository) GetById(id uint) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	if err := r.db.First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Update 更新投递记录
+func (r *DeliveryRepository) Update(d *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	if err := r.db.Save(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListBySubscription 获取某个订阅的投递记录（按时间倒序）
+func (r *DeliveryRepository) ListBySubscription(subID uint, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.Where("subscription_id = ?", subID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListDueForRetry 获取到期需要重试的投递记录
+func (r *DeliveryRepository) ListDueForRetry(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.Where("status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?", model.DeliveryStatusPending, now).
+		Order("next_retry_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// EventRepository 待分发事件（发件箱）仓库
+type EventRepository struct {
+	db *gorm.DB
+}
+
+// NewEventRepository 创建待分发事件仓库
+func NewEventRepository(db *gorm.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// Create 记录待分发的事件
+func (r *EventRepository) Create(e *model.WebhookEvent) error {
+	return r.db.Create(e).Error
+}
+
+// ListPending 按产生顺序获取尚未分发的事件
+func (r *EventRepository) ListPending(limit int) ([]model.WebhookEvent, error) {
+	var events []model.WebhookEvent
+	err := r.db.Where("dispatched_at IS NULL").Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// CountPending 统计尚未分发的事件数量
+func (r *EventRepository) CountPending() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.WebhookEvent{}).Where("dispatched_at IS NULL").Count(&count).Error
+	return count, err
+}
+
+// MarkDispatched 标记事件已分发，事件已被其他实例分发时返回 false
+func (r *EventRepository) MarkDispatched(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&model.WebhookEvent{}).Where("id = ? AND dispatched_at IS NULL", id).Update("dispatched_at", at)
+	return result.RowsAffected > 0, result.Error
+}
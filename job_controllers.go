@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/internal/scheduler"
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	defaultExecutionLimit = 50
+	maxExecutionLimit     = 500
+)
+
+// parseJobID 解析路由中的定时任务ID
+func parseJobID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	taskID, err := strconv.ParseUint(mux.Vars(r)["taskId"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid task ID")
+		return 0, false
+	}
+	return uint(taskID), true
+}
+
+// loadJob 获取路由中的定时任务，并校验当前用户在其命名空间内拥有指定权限
+func loadJob(w http.ResponseWriter, r *http.Request, perm Permission) (*model.Task, bool) {
+	taskID, ok := parseJobID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	task, err := repository.NewTaskRepository(jobDB.WithContext(r.Context())).GetById(taskID)
+	if err != nil {
+		logger.Errorf("Error getting task: %v", err)
+		sendJobError(w, err, "Failed to get task")
+		return nil, false
+	}
+	if !authorizeNamespace(w, r, task.NamespaceID, perm) {
+		return nil, false
+	}
+	return task, true
+}
+
+// sendJobError 根据错误类型返回 404 或 500
+func sendJobError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errJobNotFound
+	}
+	sendError(w, err, message)
+}
+
+// getJobsHandler 获取当前用户可见命名空间中的定时任务，可按 namespace_id 过滤
+func getJobsHandler(w http.ResponseWriter, r *http.Request) {
+	namespaces, err := visibleNamespaces(r)
+	if err != nil {
+		logger.Errorf("Error getting namespaces: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get namespaces")
+		return
+	}
+
+	var filter uint64
+	if v := r.URL.Query().Get("namespace_id"); v != "" {
+		filter, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid namespace ID")
+			return
+		}
+	}
+
+	var ids []uint
+	for _, ns := range namespaces {
+		if filter == 0 || uint64(ns.ID) == filter {
+			ids = append(ids, ns.ID)
+		}
+	}
+	if filter != 0 && len(ids) == 0 {
+		sendForbidden(w)
+		return
+	}
+
+	tasks, err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).ListTasks(ids)
+	if err != nil {
+		logger.Errorf("Error getting tasks: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get tasks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// auditSchedulerEvent 记录调度器自身发起的任务启停，操作者为 system
+func auditSchedulerEvent(event model.EventType, before, after *model.Task) {
+	action := auditActionStart
+	if event == model.EventTaskStopped {
+		action = auditActionStop
+	}
+	recordSystemAudit(action, auditResourceJob, after.ID, before, after)
+}
+
+// startJobHandler 启动定时任务
+func startJobHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permOperateJobs)
+	if !ok {
+		return
+	}
+
+	before := *task
+	if err := jobScheduler.StartTask(task); err != nil {
+		logger.Errorf("Error starting task %d: %v", task.ID, err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to start task")
+		return
+	}
+	recordAudit(r, auditActionStart, auditResourceJob, task.ID, &before, task)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// stopJobHandler 停止定时任务
+func stopJobHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permOperateJobs)
+	if !ok {
+		return
+	}
+
+	if err := jobScheduler.StopTaskByID(task.ID); err != nil {
+		logger.Errorf("Error stopping task %d: %v", task.ID, err)
+		sendJobError(w, err, "Failed to stop task")
+		return
+	}
+	after := *task
+	after.Status = model.TaskStatusStopped
+	recordAudit(r, auditActionStop, auditResourceJob, task.ID, task, &after)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runJobHandler 立即执行一次定时任务
+func runJobHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permOperateJobs)
+	if !ok {
+		return
+	}
+
+	executionID, err := jobScheduler.ExecuteTaskNowContext(r.Context(), task.ID)
+	if err != nil {
+		logger.Errorf("Error executing task %d: %v", task.ID, err)
+		sendJobError(w, err, "Failed to execute task")
+		return
+	}
+	recordAudit(r, auditActionRun, auditResourceJob, task.ID, nil, map[string]uint{"execution_id": executionID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]uint{"execution_id": executionID})
+}
+
+// getJobExecutionsHandler 获取定时任务最近的执行记录
+func getJobExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	limit := defaultExecutionLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxExecutionLimit {
+			sendErrorResponse(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	executions, err := repository.NewStatsRepository(jobDB.WithContext(r.Context())).ListRecentExecutions(task.ID, limit)
+	if err != nil {
+		logger.Errorf("Error getting executions: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get executions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(executions)
+}
+
+// revisionContext 返回携带当前用户作为修订作者的 context
+func revisionContext(r *http.Request) context.Context {
+	user := currentUser(r)
+	return scheduler.WithRevisionInfo(r.Context(), scheduler.RevisionInfo{
+		AuthorID:   uint(user.ID),
+		AuthorName: user.Username,
+	})
+}
+
+// parseRevision 解析修订号
+func parseRevision(w http.ResponseWriter, value, name string) (int, bool) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return n, true
+}
+
+// getRevision 获取任务的指定修订，写入 404/500 错误响应
+func getRevision(w http.ResponseWriter, r *http.Request, taskID uint, revision int) (*model.TaskRevision, bool) {
+	rev, err := repository.NewRevisionRepository(jobDB.WithContext(r.Context())).Get(taskID, revision)
+	if err != nil {
+		logger.Errorf("Error getting task revision: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Revision not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get revision")
+		}
+		return nil, false
+	}
+	return rev, true
+}
+
+// getJobRevisionsHandler 获取定时任务的修订历史
+func getJobRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	revs, err := repository.NewRevisionRepository(jobDB.WithContext(r.Context())).ListByTask(task.ID)
+	if err != nil {
+		logger.Errorf("Error getting task revisions: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(revs)
+}
+
+// getJobRevisionHandler 获取定时任务的单个修订
+func getJobRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+	revision, ok := parseRevision(w, mux.Vars(r)["revision"], "revision")
+	if !ok {
+		return
+	}
+	rev, ok := getRevision(w, r, task.ID, revision)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rev)
+}
+
+// diffJobRevisionsHandler 比较定时任务的两个修订
+func diffJobRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	fromRev, ok := parseRevision(w, query.Get("from"), "from revision")
+	if !ok {
+		return
+	}
+	toRev, ok := parseRevision(w, query.Get("to"), "to revision")
+	if !ok {
+		return
+	}
+	from, ok := getRevision(w, r, task.ID, fromRev)
+	if !ok {
+		return
+	}
+	to, ok := getRevision(w, r, task.ID, toRev)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task_id": task.ID,
+		"from":    fromRev,
+		"to":      toRev,
+		"changes": from.Diff(to),
+	})
+}
+
+// rollbackJobHandler 将定时任务回滚到指定修订
+func rollbackJobHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+	revision, ok := parseRevision(w, mux.Vars(r)["revision"], "revision")
+	if !ok {
+		return
+	}
+	rev, ok := getRevision(w, r, task.ID, revision)
+	if !ok {
+		return
+	}
+	// 回滚后的命名空间同样需要管理权限
+	if rev.NamespaceID != task.NamespaceID && !authorizeNamespace(w, r, rev.NamespaceID, permManageJobs) {
+		return
+	}
+
+	before := *task
+	updated, err := jobScheduler.RollbackTask(revisionContext(r), task.ID, revision)
+	if err != nil {
+		logger.Errorf("Error rolling back task %d to revision %d: %v", task.ID, revision, err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Task name already exists in the namespace")
+		} else {
+			// 旧修订不满足当前的校验规则时返回字段错误
+			sendError(w, err, "Failed to roll back task")
+		}
+		return
+	}
+	recordAudit(r, auditActionRollback, auditResourceJob, task.ID, &before, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"task-scheduler/pkg/logger"
+)
+
+// labelOwner 解析项目/标签的所有者：默认当前用户，管理他人的需要权限
+func labelOwner(w http.ResponseWriter, r *http.Request, userID int) (int, bool) {
+	caller := currentUser(r)
+	if userID == 0 {
+		return caller.ID, true
+	}
+	if !canAccessUser(caller, userID, permManageAllTodos) {
+		sendForbidden(w)
+		return 0, false
+	}
+	if _, err := GetUserByID(r.Context(), userID); err != nil {
+		logger.Errorf("Error checking user existence: %v", err)
+		sendError(w, err, "Failed to check user existence")
+		return 0, false
+	}
+	return userID, true
+}
+
+// labelOwnerFromQuery 从 user_id 查询参数解析列表的所有者
+func labelOwnerFromQuery(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var userID int
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid user_id")
+			return 0, false
+		}
+		userID = id
+	}
+	return labelOwner(w, r, userID)
+}
+
+// loadProject 获取路由中的项目并校验访问权限
+func loadProject(w http.ResponseWriter, r *http.Request) (*Project, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid project ID")
+		return nil, false
+	}
+
+	project, err := GetProjectByID(id)
+	if err != nil {
+		logger.Errorf("Error getting project: %v", err)
+		sendError(w, err, "Failed to get project")
+		return nil, false
+	}
+	if !canAccessUser(currentUser(r), project.UserID, permManageAllTodos) {
+		sendForbidden(w)
+		return nil, false
+	}
+	return project, true
+}
+
+// getProjectsHandler 获取用户的项目
+func getProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := labelOwnerFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	projects, err := GetProjectsByUserID(userID)
+	if err != nil {
+		logger.Errorf("Error getting projects: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get projects")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(projects)
+}
+
+// createProjectHandler 创建项目，未指定用户时归属当前用户
+func createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var project Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		logger.Errorf("Error decoding project: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := project.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	userID, ok := labelOwner(w, r, project.UserID)
+	if !ok {
+		return
+	}
+	project.UserID = userID
+
+	if err := CreateProject(&project); err != nil {
+		logger.Errorf("Error creating project: %v", err)
+		sendError(w, err, "Failed to create project")
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceProject, project.ID, nil, &project)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+// getProjectHandler 根据ID获取项目
+func getProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := loadProject(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(project)
+}
+
+// updateProjectHandler 修改项目名称、描述和颜色
+func updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := loadProject(w, r)
+	if !ok {
+		return
+	}
+
+	var req Project
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding project update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	before := *project
+	project.Name = req.Name
+	project.Description = req.Description
+	project.Color = req.Color
+	if err := UpdateProject(project); err != nil {
+		logger.Errorf("Error updating project: %v", err)
+		sendError(w, err, "Failed to update project")
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceProject, project.ID, &before, project)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(project)
+}
+
+// deleteProjectHandler 删除项目；tasks=inbox（默认）将其中的任务移到收件箱，tasks=delete 一并删除
+func deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := loadProject(w, r)
+	if !ok {
+		return
+	}
+
+	tasks := r.URL.Query().Get("tasks")
+	switch tasks {
+	case "":
+		tasks = projectTasksInbox
+	case projectTasksInbox, projectTasksDelete:
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "tasks must be inbox or delete")
+		return
+	}
+
+	if tasks == projectTasksDelete {
+		if err := deleteProjectTasks(r, project.ID); err != nil {
+			logger.Errorf("Error deleting tasks of project %d: %v", project.ID, err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete project tasks")
+			return
+		}
+	}
+	caller := currentUser(r)
+	if err := DeleteProject(project.ID, tasks, &caller.ID); err != nil {
+		logger.Errorf("Error deleting project: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete project")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceProject, project.ID, project, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteProjectTasks 按普通的任务删除流程逐个删除项目中的任务：结束所在的系列（项目删除后系列不应继续生成实例）、
+// 为每个任务记录审计；已随父任务或系列一起删除的任务跳过
+func deleteProjectTasks(r *http.Request, projectID int) error {
+	ids, err := GetProjectTaskIDs(projectID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		task, err := GetTaskByID(r.Context(), id)
+		if errors.Is(err, errTaskNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := removeTask(r, task, seriesScopeFuture); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTag 获取路由中的标签并校验访问权限
+func loadTag(w http.ResponseWriter, r *http.Request) (*Tag, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid tag ID")
+		return nil, false
+	}
+
+	tag, err := GetTagByID(id)
+	if err != nil {
+		logger.Errorf("Error getting tag: %v", err)
+		sendError(w, err, "Failed to get tag")
+		return nil, false
+	}
+	if !canAccessUser(currentUser(r), tag.UserID, permManageAllTodos) {
+		sendForbidden(w)
+		return nil, false
+	}
+	return tag, true
+}
+
+// getTagsHandler 获取用户的标签
+func getTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := labelOwnerFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := GetTagsByUserID(userID)
+	if err != nil {
+		logger.Errorf("Error getting tags: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get tags")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tags)
+}
+
+// createTagHandler 创建标签，未指定用户时归属当前用户
+func createTagHandler(w http.ResponseWriter, r *http.Request) {
+	var tag Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		logger.Errorf("Error decoding tag: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := tag.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	userID, ok := labelOwner(w, r, tag.UserID)
+	if !ok {
+		return
+	}
+	tag.UserID = userID
+
+	if err := CreateTag(&tag); err != nil {
+		logger.Errorf("Error creating tag: %v", err)
+		sendError(w, err, "Failed to create tag")
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceTag, tag.ID, nil, &tag)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// updateTagHandler 修改标签名称和颜色
+func updateTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := loadTag(w, r)
+	if !ok {
+		return
+	}
+
+	var req Tag
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding tag update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	before := *tag
+	tag.Name = req.Name
+	tag.Color = req.Color
+	if err := UpdateTag(tag); err != nil {
+		logger.Errorf("Error updating tag: %v", err)
+		sendError(w, err, "Failed to update tag")
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceTag, tag.ID, &before, tag)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// deleteTagHandler 删除标签，并从所有任务上移除
+func deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := loadTag(w, r)
+	if !ok {
+		return
+	}
+
+	if err := DeleteTag(tag.ID); err != nil {
+		logger.Errorf("Error deleting tag: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete tag")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceTag, tag.ID, tag, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
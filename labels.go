@@ -0,0 +1,359 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"task-scheduler/pkg/validation"
+)
+
+const (
+	maxTaskTags = 20
+
+	// 删除项目时其中任务的处理方式
+	projectTasksInbox  = "inbox"  // 移到收件箱（不属于任何项目）
+	projectTasksDelete = "delete" // 连同项目一起删除
+)
+
+// Project 项目，将同一用户的任务分组；任务不属于任何项目时位于收件箱
+type Project struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Color       string    `json:"color"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Tag 标签，与任务多对多关联
+type Tag struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	errProjectNotFound  = &appError{Kind: errNotFound, Code: "project_not_found", Message: "Project not found"}
+	errTagNotFound      = &appError{Kind: errNotFound, Code: "tag_not_found", Message: "Tag not found"}
+	errProjectNameTaken = &appError{Kind: errConflict, Code: "project_name_taken", Message: "A project with this name already exists"}
+	errTagNameTaken     = &appError{Kind: errConflict, Code: "tag_name_taken", Message: "A tag with this name already exists"}
+)
+
+// labelDuplicate 名称唯一约束冲突时返回 taken，其他错误原样返回
+func labelDuplicate(err error, taken error) error {
+	if translateDuplicate(err) == errDuplicate {
+		return taken
+	}
+	return err
+}
+
+const projectColumns = "id, user_id, name, description, color, created_at, updated_at"
+
+// scanProject 从查询结果中读取项目
+func scanProject(scanner interface{ Scan(...interface{}) error }) (*Project, error) {
+	var p Project
+	err := scanner.Scan(&p.ID, &p.UserID, &p.Name, &p.Description, &p.Color, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateProject 创建项目
+func CreateProject(p *Project) error {
+	result, err := db.Exec(
+		"INSERT INTO projects (user_id, name, description, color) VALUES (?, ?, ?, ?)",
+		p.UserID, p.Name, p.Description, p.Color,
+	)
+	if err != nil {
+		return labelDuplicate(err, errProjectNameTaken)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	p.ID = int(id)
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	return nil
+}
+
+// GetProjectByID 根据ID获取项目
+func GetProjectByID(id int) (*Project, error) {
+	p, err := scanProject(db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errProjectNotFound
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetProjectsByUserID 获取用户的所有项目
+func GetProjectsByUserID(userID int) ([]Project, error) {
+	rows, err := db.Query("SELECT "+projectColumns+" FROM projects WHERE user_id = ? ORDER BY name, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, *p)
+	}
+	return projects, rows.Err()
+}
+
+// UpdateProject 更新项目名称、描述和颜色
+func UpdateProject(p *Project) error {
+	_, err := db.Exec(
+		"UPDATE projects SET name = ?, description = ?, color = ? WHERE id = ?",
+		p.Name, p.Description, p.Color, p.ID,
+	)
+	if err != nil {
+		return labelDuplicate(err, errProjectNameTaken)
+	}
+	p.UpdatedAt = time.Now()
+	return nil
+}
+
+// GetProjectTaskIDs 获取项目中的任务ID
+func GetProjectTaskIDs(projectID int) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM tasks WHERE project_id = ? ORDER BY id", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteProject 删除项目，tasks 决定其中的任务移到收件箱还是一并删除；
+// 一并删除时调用方应先经 deleteProjectTasks 逐个删除，这里只删除期间新加入的任务
+func DeleteProject(id int, tasks string, actorID *int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if tasks == projectTasksDelete {
+		_, err = tx.Exec("DELETE FROM tasks WHERE project_id = ?", id)
+	} else {
+		// 状态因映射而改变的任务记录状态历史，与移动在同一事务中完成
+		_, err = tx.Exec(
+			`INSERT INTO task_status_history (task_id, from_status, to_status, to_category, actor_id)
+			SELECT t.id, t.status, s.category, s.category, ?
+			FROM tasks t JOIN project_statuses s ON s.project_id = t.project_id AND s.status = t.status
+			WHERE t.project_id = ? AND s.category <> t.status`,
+			actorID, id,
+		)
+		if err != nil {
+			return err
+		}
+		// 项目工作流中的状态按类别映射到收件箱（默认工作流）中的同名类别状态
+		_, err = tx.Exec(
+			`UPDATE tasks t LEFT JOIN project_statuses s ON s.project_id = t.project_id AND s.status = t.status
+			SET t.status = COALESCE(s.category, t.status), t.project_id = NULL, t.version = t.version + 1
+			WHERE t.project_id = ?`,
+			id,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM projects WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const tagColumns = "id, user_id, name, color, created_at"
+
+// scanTag 从查询结果中读取标签
+func scanTag(scanner interface{ Scan(...interface{}) error }) (*Tag, error) {
+	var t Tag
+	if err := scanner.Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTag 创建标签
+func CreateTag(t *Tag) error {
+	result, err := db.Exec("INSERT INTO tags (user_id, name, color) VALUES (?, ?, ?)", t.UserID, t.Name, t.Color)
+	if err != nil {
+		return labelDuplicate(err, errTagNameTaken)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	t.ID = int(id)
+	t.CreatedAt = time.Now()
+	return nil
+}
+
+// GetTagByID 根据ID获取标签
+func GetTagByID(id int) (*Tag, error) {
+	t, err := scanTag(db.QueryRow("SELECT "+tagColumns+" FROM tags WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errTagNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetTagsByUserID 获取用户的所有标签
+func GetTagsByUserID(userID int) ([]Tag, error) {
+	rows, err := db.Query("SELECT "+tagColumns+" FROM tags WHERE user_id = ? ORDER BY name, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *t)
+	}
+	return tags, rows.Err()
+}
+
+// UpdateTag 更新标签名称和颜色
+func UpdateTag(t *Tag) error {
+	_, err := db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", t.Name, t.Color, t.ID)
+	return labelDuplicate(err, errTagNameTaken)
+}
+
+// DeleteTag 删除标签，任务上的关联随之删除
+func DeleteTag(id int) error {
+	_, err := db.Exec("DELETE FROM tags WHERE id = ?", id)
+	return err
+}
+
+// checkTaskLabels 校验任务的项目和标签都属于任务所有者，并去除重复的标签
+func checkTaskLabels(task *Task) error {
+	var v validation.Validator
+	if task.ProjectID != nil {
+		var owner int
+		err := db.QueryRow("SELECT user_id FROM projects WHERE id = ?", *task.ProjectID).Scan(&owner)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		v.Check(err == nil && owner == task.UserID, "project_id", validation.CodeNotAllowed,
+			"must be a project of the task owner")
+	}
+
+	seen := make(map[int]bool)
+	tagIDs := []int{}
+	for _, id := range task.TagIDs {
+		if !seen[id] {
+			seen[id] = true
+			tagIDs = append(tagIDs, id)
+		}
+	}
+	task.TagIDs = tagIDs
+	if len(tagIDs) > maxTaskTags {
+		v.Add("tag_ids", validation.CodeTooLong, fmt.Sprintf("must contain at most %d tags", maxTaskTags))
+	} else if len(tagIDs) > 0 {
+		args := []interface{}{task.UserID}
+		for _, id := range tagIDs {
+			args = append(args, id)
+		}
+		var count int
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM tags WHERE user_id = ? AND id IN ("+placeholders(len(tagIDs))+")", args...,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		v.Check(count == len(tagIDs), "tag_ids", validation.CodeNotAllowed, "must be tags of the task owner")
+	}
+	return v.Err()
+}
+
+// syncTaskTags 在调用方的事务中将任务的标签关联更新为 task.TagIDs
+func syncTaskTags(tx *sql.Tx, task *Task) error {
+	if task.TagIDs == nil {
+		task.TagIDs = []int{}
+	}
+
+	args := []interface{}{task.ID}
+	for _, id := range task.TagIDs {
+		args = append(args, id)
+	}
+	query := "DELETE FROM task_tags WHERE task_id = ?"
+	if len(task.TagIDs) > 0 {
+		query += " AND tag_id NOT IN (" + placeholders(len(task.TagIDs)) + ")"
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, id := range task.TagIDs {
+		if _, err := tx.Exec("INSERT IGNORE INTO task_tags (task_id, tag_id) VALUES (?, ?)", task.ID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTaskTags 为一组任务填充标签
+func loadTaskTags(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	index := make(map[int]*Task, len(tasks))
+	args := make([]interface{}, len(tasks))
+	for i := range tasks {
+		tasks[i].TagIDs = []int{}
+		index[tasks[i].ID] = &tasks[i]
+		args[i] = tasks[i].ID
+	}
+
+	rows, err := db.Query(
+		"SELECT task_id, tag_id FROM task_tags WHERE task_id IN ("+placeholders(len(tasks))+") ORDER BY tag_id",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, tagID int
+		if err := rows.Scan(&taskID, &tagID); err != nil {
+			return err
+		}
+		if task, ok := index[taskID]; ok {
+			task.TagIDs = append(task.TagIDs, tagID)
+		}
+	}
+	return rows.Err()
+}
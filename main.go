@@ -9,11 +9,25 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
+
 	"task-scheduler/api"
 	"task-scheduler/config"
+	"task-scheduler/internal/repository"
 	"task-scheduler/internal/scheduler"
+	"task-scheduler/pkg/blob"
 	"task-scheduler/pkg/database"
 	"task-scheduler/pkg/logger"
+	"task-scheduler/pkg/mail"
+	"task-scheduler/pkg/tracing"
+)
+
+// 调度相关的处理函数（webhook等）通过以下变量访问数据库和调度器
+var (
+	jobDB        *gorm.DB
+	jobScheduler *scheduler.Scheduler
 )
 
 func main() {
@@ -26,6 +40,33 @@ func main() {
 	// 初始化日志
 	logger.InitLogger(cfg.Log.Level)
 	
+	// 初始化链路追踪
+	shutdownTracer, err := tracing.InitTracer(context.Background(), tracing.ConfigFromEnv())
+	if err != nil {
+		logger.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracer(ctx); err != nil {
+			logger.Warnf("Failed to flush traces: %v", err)
+		}
+	}()
+	
+	// 初始化账号邮件发送器
+	sender, err := mail.NewSender(mail.ConfigFromEnv())
+	if err != nil {
+		logger.Fatalf("Failed to initialize mail sender: %v", err)
+	}
+	mailSender = sender
+	
+	// 初始化附件的对象存储
+	store, err := blob.NewStore(blob.ConfigFromEnv())
+	if err != nil {
+		logger.Fatalf("Failed to initialize blob store: %v", err)
+	}
+	blobStore = store
+	
 	// 初始化数据库连接
 	db, err := database.InitDB(cfg.Database)
 	if err != nil {
@@ -33,17 +74,67 @@ func main() {
 	}
 	defer database.CloseDB(db)
 	
+	// 创建或补齐调度器相关的表
+	if err := scheduler.Migrate(db); err != nil {
+		logger.Fatalf("Failed to migrate job database: %v", err)
+	}
+	
+	// 原生 SQL 仓库与调度器共用连接池，须在挂载路由前完成建表
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Fatalf("Failed to get database connection pool: %v", err)
+	}
+	if err := initSQLDB(context.Background(), sqlDB); err != nil {
+		logger.Fatalf("Failed to initialize application schema: %v", err)
+	}
+	
+	// 为仓库层的所有查询生成span
+	if err := db.Use(gormtracing.NewPlugin(gormtracing.WithoutMetrics())); err != nil {
+		logger.Warnf("Failed to enable database tracing: %v", err)
+	}
+	
+	// 确保默认命名空间存在，历史任务归入其中
+	if _, err := repository.NewNamespaceRepository(db).EnsureDefault(); err != nil {
+		logger.Warnf("Failed to ensure default namespace: %v", err)
+	}
+	
 	// 初始化任务调度器
 	scheduler := scheduler.NewScheduler(db)
 	defer scheduler.Stop()
+	jobDB = db
+	jobScheduler = scheduler
+	scheduler.SetAuditFunc(auditSchedulerEvent)
+	
+	// 由调度器定期检查待办任务的提醒，经通知通道投递
+	startReminderWorker()
+	if err := scheduler.RegisterMaintenanceJob(reminderCheckInterval, fireDueReminders); err != nil {
+		logger.Warnf("Failed to register reminder job: %v", err)
+	}
+	// 重复待办任务的规则触发后生成下一个实例
+	if err := scheduler.RegisterMaintenanceJob(recurrenceCheckInterval, advanceRecurringTasks); err != nil {
+		logger.Warnf("Failed to register recurring task job: %v", err)
+	}
+	// 清理已删除任务遗留的附件内容
+	if err := scheduler.RegisterMaintenanceJob(attachmentCleanupInterval, cleanupOrphanAttachments); err != nil {
+		logger.Warnf("Failed to register attachment cleanup job: %v", err)
+	}
+	// 清理过期的登录失败记录
+	if err := scheduler.RegisterMaintenanceJob(loginFailureInterval, pruneLoginFailures); err != nil {
+		logger.Warnf("Failed to register login failure cleanup job: %v", err)
+	}
+	
+	// 注册调度器和数据库指标
+	if err := scheduler.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		logger.Warnf("Failed to register scheduler metrics: %v", err)
+	}
 	
 	// 从数据库加载并启动所有启用的任务
 	if err := scheduler.LoadAndStartTasks(); err != nil {
 		logger.Warnf("Failed to load and start tasks: %v", err)
 	}
 	
-	// 设置路由
-	router := api.SetupRouter(db, scheduler)
+	// 设置路由，原有的 API 路由在认证后处理 /api 下的其余路径，仅系统管理员可用
+	router := newRouter(api.SetupRouter(db, scheduler))
 	
 	// 创建HTTP服务器
 	server := &http.Server{
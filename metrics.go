@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/pkg/logger"
+)
+
+var (
+	executionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "taskflow",
+			Name:      "executions_total",
+			Help:      "Number of finished task executions by task and status.",
+		},
+		[]string{"task_id", "status"},
+	)
+
+	executionDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "taskflow",
+			Name:      "execution_duration_seconds",
+			Help:      "Duration of finished task executions.",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600},
+		},
+		[]string{"task_id"},
+	)
+)
+
+// schedulerCollector 在每次抓取时读取调度器的当前状态
+type schedulerCollector struct {
+	s *Scheduler
+
+	registeredJobs    *prometheus.Desc
+	runningExecutions *prometheus.Desc
+	queueDepth        *prometheus.Desc
+	webhookQueueDepth *prometheus.Desc
+}
+
+func newSchedulerCollector(s *Scheduler) *schedulerCollector {
+	return &schedulerCollector{
+		s: s,
+		registeredJobs: prometheus.NewDesc(
+			"taskflow_scheduler_registered_jobs",
+			"Number of jobs registered with the scheduler.",
+			nil, nil,
+		),
+		runningExecutions: prometheus.NewDesc(
+			"taskflow_scheduler_running_executions",
+			"Number of executions currently in running state.",
+			nil, nil,
+		),
+		queueDepth: prometheus.NewDesc(
+			"taskflow_scheduler_queue_depth",
+			"Number of job runs waiting in the scheduler queue.",
+			nil, nil,
+		),
+		webhookQueueDepth: prometheus.NewDesc(
+			"taskflow_webhook_queue_depth",
+			"Number of webhook deliveries waiting to be sent.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *schedulerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.registeredJobs
+	ch <- c.runningExecutions
+	ch <- c.queueDepth
+	ch <- c.webhookQueueDepth
+}
+
+// Collect 实现 prometheus.Collector
+func (c *schedulerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.s.mu.RLock()
+	jobs := len(c.s.jobs)
+	c.s.mu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(c.registeredJobs, prometheus.GaugeValue, float64(jobs))
+
+	var running int64
+	if err := c.s.db.Model(&model.TaskExecution{}).
+		Where("status = ?", model.ExecutionStatusRunning).
+		Count(&running).Error; err != nil {
+		logger.Warnf("Failed to count running executions: %v", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.runningExecutions, prometheus.GaugeValue, float64(running))
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(c.s.gc.JobsWaitingInQueue()))
+	ch <- prometheus.MustNewConstMetric(c.webhookQueueDepth, prometheus.GaugeValue, float64(c.s.webhooks.QueueDepth()))
+}
+
+// RegisterMetrics 注册调度器、执行记录和数据库连接池指标
+func (s *Scheduler) RegisterMetrics(reg prometheus.Registerer) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range []prometheus.Collector{
+		executionsTotal,
+		executionDuration,
+		newSchedulerCollector(s),
+		collectors.NewDBStatsCollector(sqlDB, "taskflow"),
+	} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return registerMetricsCallbacks(s.db)
+}
+
+// metricsFinishingKey 更新前判断执行记录是否由运行中进入终态，结果保存在语句实例上
+const metricsFinishingKey = "metrics:finishing"
+
+// registerMetricsCallbacks 在执行记录由运行中进入终态时记录计数和耗时，已结束的记录再次保存不重复计数
+func registerMetricsCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Update().Before("gorm:update").Register("metrics:before_update", func(tx *gorm.DB) {
+		e, ok := tx.Statement.Model.(*model.TaskExecution)
+		if !ok || e.ID == 0 || e.Status == model.ExecutionStatusRunning || e.EndTime == nil {
+			return
+		}
+
+		var previous []model.ExecutionStatus
+		err := tx.Session(&gorm.Session{NewDB: true}).
+			Model(&model.TaskExecution{}).
+			Where("id = ?", e.ID).
+			Pluck("status", &previous).Error
+		if err != nil {
+			logger.Warnf("Failed to read status of execution %d: %v", e.ID, err)
+			return
+		}
+		tx.InstanceSet(metricsFinishingKey, len(previous) == 1 && previous[0] == model.ExecutionStatusRunning)
+	}); err != nil {
+		return err
+	}
+
+	return db.Callback().Update().After("gorm:update").Register("metrics:after_update", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			return
+		}
+		if finishing, _ := tx.InstanceGet(metricsFinishingKey); finishing != true {
+			return
+		}
+		e := tx.Statement.Model.(*model.TaskExecution)
+
+		taskID := strconv.FormatUint(uint64(e.TaskID), 10)
+		executionsTotal.WithLabelValues(taskID, string(e.Status)).Inc()
+		executionDuration.WithLabelValues(taskID).Observe(e.EndTime.Sub(e.StartTime).Seconds())
+	})
+}
@@ -1,9 +1,36 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
-	"fmt"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "taskflow",
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route and status.",
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	httpRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "taskflow",
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route", "status"},
+	)
 )
 
 // loggingMiddleware 记录请求日志
@@ -42,6 +69,162 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// metricsMiddleware 包裹整个路由器，记录请求数和耗时指标。按路由模板而非实际路径分组以控制标签基数，
+// 模板由路由匹配后的 routeLabelMiddleware 填写，未匹配任何路由的请求（404、405）记为 unmatched
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		route := "unmatched"
+		
+		next.ServeHTTP(lrw, r.WithContext(context.WithValue(r.Context(), routeLabelContextKey, &route)))
+		
+		status := strconv.Itoa(lrw.statusCode)
+		httpRequestsTotal.WithLabelValues(r.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeLabelMiddleware 将匹配到的路由模板告知外层的 metricsMiddleware
+func routeLabelMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeLabelContextKey).(*string); ok {
+			if current := mux.CurrentRoute(r); current != nil {
+				if tmpl, err := current.GetPathTemplate(); err == nil {
+					*route = tmpl
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contextKey 请求上下文中使用的键类型
+type contextKey string
+
+const (
+	userContextKey       contextKey = "user"
+	claimsContextKey     contextKey = "claims"
+	apiKeyContextKey     contextKey = "api_key"
+	requestIDContextKey  contextKey = "request_id"
+	routeLabelContextKey contextKey = "route_label"
+)
+
+// requestIDMiddleware 沿用调用方的 X-Request-ID 或生成新的请求ID，写入响应头和请求上下文
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			var err error
+			if id, err = randomToken(12); err != nil {
+				id = strconv.FormatInt(time.Now().UnixNano(), 36)
+			}
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+	})
+}
+
+// requestID 返回请求上下文中的请求ID
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// authMiddleware 校验 Bearer 访问令牌或 API 密钥，并将已认证的用户放入请求上下文
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendErrorResponse(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+		token := strings.TrimPrefix(header, "Bearer ")
+		
+		var userID int
+		ctx := r.Context()
+		if strings.HasPrefix(token, apiKeyPrefix) {
+			key, err := authenticateAPIKey(token)
+			if err != nil {
+				if err != errInvalidToken {
+					logger.Errorf("Error verifying api key: %v", err)
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			if !key.AllowsIP(r.RemoteAddr) {
+				sendErrorResponse(w, http.StatusForbidden, "API key is not allowed from this address")
+				return
+			}
+			if !key.HasScope(requiredScope(r)) {
+				sendErrorResponse(w, http.StatusForbidden, "API key does not have the required scope")
+				return
+			}
+			userID = key.UserID
+			ctx = context.WithValue(ctx, apiKeyContextKey, key)
+		} else {
+			claims, err := parseAccessToken(token)
+			if err != nil {
+				if err != errInvalidToken {
+					logger.Errorf("Error verifying access token: %v", err)
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			userID, err = strconv.Atoi(claims.Subject)
+			if err != nil {
+				sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			ctx = context.WithValue(ctx, claimsContextKey, claims)
+		}
+		
+		user, err := GetUserByID(r.Context(), userID)
+		if err != nil {
+			// 令牌对应的用户已被删除
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+		if user.DisabledAt != nil {
+			sendErrorResponse(w, http.StatusUnauthorized, "Account is disabled")
+			return
+		}
+		
+		ctx = context.WithValue(ctx, userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// sessionOnlyMiddleware 拒绝使用 API 密钥认证的请求，用于密钥管理等只允许登录会话的操作，
+// 避免泄露的密钥为自己续期或签发新密钥
+func sessionOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(apiKeyContextKey) != nil {
+			sendErrorResponse(w, http.StatusForbidden, "API keys must be managed from a signed-in session")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiredScope 返回请求所需的 API 密钥作用域
+func requiredScope(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return scopeRead
+	}
+	return scopeWrite
+}
+
+// currentUser 返回请求上下文中的已认证用户
+func currentUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey).(*User)
+	return user
+}
+
 // recoverMiddleware 捕获恐慌并返回适当的错误响应
 func recoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -56,15 +239,7 @@ func recoverMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// sendErrorResponse 发送标准化的错误响应
-func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	
-	response := map[string]string{
-		"error": message,
-		"code":  fmt.Sprintf("%d", statusCode),
-	}
-	
-	json.NewEncoder(w).Encode(response)
+// sendErrorResponse 发送标准化的错误响应（RFC 7807），错误码由状态码生成
+func sendErrorResponse(w http.ResponseWriter, status int, message string) {
+	writeProblem(w, status, statusCode(status), message, nil)
 }
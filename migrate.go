@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+)
+
+// jobModels 调度器使用的模型，启动时自动迁移表结构
+var jobModels = []interface{}{
+	&model.Namespace{},
+	&model.NamespaceMember{},
+	&model.Task{},
+	&model.TaskExecution{},
+	&model.TaskRevision{},
+	&model.WebhookSubscription{},
+	&model.WebhookDelivery{},
+	&model.WebhookEvent{},
+	&model.RetentionPolicy{},
+	&model.Secret{},
+	&model.Calendar{},
+}
+
+// Migrate 创建缺失的表和列，需在调度器和仓库使用数据库之前调用
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(jobModels...)
+}
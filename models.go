@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"time"
 )
 
@@ -11,9 +11,16 @@ type User struct {
 	ID           int       `json:"id"`
 	Username     string    `json:"username"`
 	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // 不返回密码哈希
+	Password     string    `json:"password,omitempty"` // 仅用于接收明文密码，不落库
+	PasswordHash string    `json:"-"`                  // 不返回密码哈希
+	Role         string    `json:"role"`
+	Version      int       `json:"version"` // 乐观锁版本号，与 ETag 对应
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	// 账号状态，由专门的接口维护，UpdateUser 不会修改
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
 }
 
 // Task 模型定义
@@ -23,18 +30,82 @@ type Task struct {
 	Description string    `json:"description"`
 	Completed   bool      `json:"completed"`
 	UserID      int       `json:"user_id"`
+	Version     int       `json:"version"` // 乐观锁版本号，与 ETag 对应
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 截止时间、优先级与提醒
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	Priority        string     `json:"priority"`
+	ReminderOffsets []int      `json:"reminder_offsets"` // 截止前多少分钟提醒
+
+	// 重复任务：同一系列的实例共享 series_id，recurrence 为系列的 5 段 cron 表达式
+	SeriesID   *int   `json:"series_id,omitempty"`
+	Recurrence string `json:"recurrence,omitempty"`
+
+	// 所属项目（为空表示收件箱）与标签
+	ProjectID *int  `json:"project_id"`
+	TagIDs    []int `json:"tag_ids"`
+
+	// 子任务与检查项；RequireChildrenComplete 为真时子任务全部完成前不能完成该任务
+	ParentID                *int            `json:"parent_id"`
+	RequireChildrenComplete bool            `json:"require_children_complete"`
+	Checklist               []ChecklistItem `json:"checklist"`
+	Progress                *TaskProgress   `json:"progress,omitempty"` // 只读，由子任务和检查项计算
+
+	// 工作流状态（completed 由状态类别决定）与看板中的排序
+	Status          string     `json:"status"`
+	Rank            float64    `json:"rank"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"` // 只读，进入当前状态的时间
+
+	// 创建者（只读）、负责人与关注者；UserID 为任务所在列表的所有者
+	CreatedBy   *int  `json:"created_by"`
+	AssigneeIDs []int `json:"assignee_ids"`
+	WatcherIDs  []int `json:"watcher_ids"`
+}
+
+// 任务优先级
+const (
+	priorityLow    = "low"
+	priorityNormal = "normal"
+	priorityHigh   = "high"
+	priorityUrgent = "urgent"
+)
+
+// taskColumns 任务查询的列，与 scanTask 的顺序一致；已结束系列的重复规则为空
+const taskColumns = "id, title, description, completed, user_id, version, created_at, updated_at, due_at, priority, project_id, " +
+	"parent_id, require_children_complete, status, board_rank, status_changed_at, created_by, series_id, " +
+	"(SELECT recurrence FROM task_series WHERE task_series.id = tasks.series_id AND task_series.ended_at IS NULL)"
+
+// scanTask 从查询结果中读取任务（不含提醒、标签、检查项、负责人和关注者）
+func scanTask(scanner interface{ Scan(...interface{}) error }) (*Task, error) {
+	var task Task
+	var recurrence sql.NullString
+	err := scanner.Scan(
+		&task.ID, &task.Title, &task.Description, &task.Completed,
+		&task.UserID, &task.Version, &task.CreatedAt, &task.UpdatedAt,
+		&task.DueAt, &task.Priority, &task.ProjectID,
+		&task.ParentID, &task.RequireChildrenComplete, &task.Status, &task.Rank, &task.StatusChangedAt,
+		&task.CreatedBy, &task.SeriesID, &recurrence,
+	)
+	if err != nil {
+		return nil, err
+	}
+	task.Recurrence = recurrence.String
+	return &task, nil
 }
 
 // CreateUser 创建新用户
-func CreateUser(user *User) error {
-	result, err := db.Exec(
-		"INSERT INTO users (username, email, password_hash) VALUES (?, ?, ?)",
-		user.Username, user.Email, user.PasswordHash,
+func CreateUser(ctx context.Context, user *User) (err error) {
+	ctx, span := startDBSpan(ctx, "CreateUser", "users")
+	defer func() { endDBSpan(span, err) }()
+
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO users (username, email, password_hash, role) VALUES (?, ?, ?, ?)",
+		user.Username, user.Email, user.PasswordHash, user.Role,
 	)
 	if err != nil {
-		return err
+		return translateDuplicate(err)
 	}
 
 	id, err := result.LastInsertId()
@@ -43,46 +114,101 @@ func CreateUser(user *User) error {
 	}
 
 	user.ID = int(id)
+	user.Version = 1
 	return nil
 }
 
-// GetAllUsers 获取所有用户
-func GetAllUsers() ([]User, error) {
-	rows, err := db.Query("SELECT id, username, email, created_at, updated_at FROM users")
+// UserFilter 用户列表的过滤条件
+type UserFilter struct {
+	Role        string
+	Query       string // 用户名或邮箱包含的文本
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// userSortFields 用户列表允许排序的字段
+var userSortFields = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// ListUsers 分页查询用户，同时返回符合条件的总数
+func ListUsers(ctx context.Context, filter UserFilter, page Page) (_ []User, _ int, err error) {
+	ctx, span := startDBSpan(ctx, "ListUsers", "users")
+	defer func() { endDBSpan(span, err) }()
+
+	var conds []string
+	var args []interface{}
+	if filter.Role != "" {
+		conds = append(conds, "role = ?")
+		args = append(args, filter.Role)
+	}
+	if filter.Query != "" {
+		conds = append(conds, "(username LIKE ? OR email LIKE ?)")
+		args = append(args, likePattern(filter.Query), likePattern(filter.Query))
+	}
+	if filter.CreatedFrom != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		conds = append(conds, "created_at < ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	where := whereClause(conds)
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, username, email, role, version, created_at, updated_at, email_verified_at, disabled_at FROM users"+
+			where+" ORDER BY "+page.OrderBy+" LIMIT ? OFFSET ?",
+		append(args, page.Limit, page.Offset)...,
+	)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var users []User
+	users := []User{}
 	for rows.Next() {
 		var user User
 		err := rows.Scan(
-			&user.ID, &user.Username, &user.Email,
+			&user.ID, &user.Username, &user.Email, &user.Role, &user.Version,
 			&user.CreatedAt, &user.UpdatedAt,
+			&user.EmailVerifiedAt, &user.DisabledAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, total, rows.Err()
 }
 
 // GetUserByID 根据ID获取用户
-func GetUserByID(id int) (*User, error) {
+func GetUserByID(ctx context.Context, id int) (_ *User, err error) {
+	ctx, span := startDBSpan(ctx, "GetUserByID", "users")
+	defer func() { endDBSpan(span, err) }()
+
 	var user User
-	err := db.QueryRow(
-		"SELECT id, username, email, created_at, updated_at FROM users WHERE id = ?",
+	err = db.QueryRowContext(ctx,
+		"SELECT id, username, email, role, version, created_at, updated_at, email_verified_at, disabled_at FROM users WHERE id = ?",
 		id,
 	).Scan(
-		&user.ID, &user.Username, &user.Email,
+		&user.ID, &user.Username, &user.Email, &user.Role, &user.Version,
 		&user.CreatedAt, &user.UpdatedAt,
+		&user.EmailVerifiedAt, &user.DisabledAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, errUserNotFound
 		}
 		return nil, err
 	}
@@ -90,26 +216,82 @@ func GetUserByID(id int) (*User, error) {
 	return &user, nil
 }
 
-// UpdateUser 更新用户信息
-func UpdateUser(user *User) error {
-	_, err := db.Exec(
-		"UPDATE users SET username = ?, email = ?, password_hash = ? WHERE id = ?",
-		user.Username, user.Email, user.PasswordHash, user.ID,
+// GetUserPasswordHash 获取用户当前的密码哈希
+func GetUserPasswordHash(ctx context.Context, id int) (_ string, err error) {
+	ctx, span := startDBSpan(ctx, "GetUserPasswordHash", "users")
+	defer func() { endDBSpan(span, err) }()
+
+	var hash string
+	err = db.QueryRowContext(ctx, "SELECT password_hash FROM users WHERE id = ?", id).Scan(&hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errUserNotFound
+		}
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// UpdateUser 更新用户信息，user.Version 为预期的当前版本，版本不一致时返回 errVersionConflict
+func UpdateUser(ctx context.Context, user *User) (err error) {
+	ctx, span := startDBSpan(ctx, "UpdateUser", "users")
+	defer func() { endDBSpan(span, err) }()
+
+	result, err := db.ExecContext(ctx,
+		"UPDATE users SET username = ?, email = ?, password_hash = ?, role = ?, version = version + 1 WHERE id = ? AND version = ?",
+		user.Username, user.Email, user.PasswordHash, user.Role, user.ID, user.Version,
 	)
-	return err
+	if err != nil {
+		return translateDuplicate(err)
+	}
+	return checkVersionedUpdate(result, &user.Version)
 }
 
 // DeleteUser 删除用户
-func DeleteUser(id int) error {
-	_, err := db.Exec("DELETE FROM users WHERE id = ?", id)
+func DeleteUser(ctx context.Context, id int) (err error) {
+	ctx, span := startDBSpan(ctx, "DeleteUser", "users")
+	defer func() { endDBSpan(span, err) }()
+
+	_, err = db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
 	return err
 }
 
-// CreateTask 创建新任务
-func CreateTask(task *Task) error {
-	result, err := db.Exec(
-		"INSERT INTO tasks (title, description, completed, user_id) VALUES (?, ?, ?, ?)",
-		task.Title, task.Description, task.Completed, task.UserID,
+// CreateTask 创建新任务，带重复规则时同时创建系列，任务成为系列的第一个实例
+func CreateTask(ctx context.Context, task *Task) (err error) {
+	ctx, span := startDBSpan(ctx, "CreateTask", "tasks")
+	defer func() { endDBSpan(span, err) }()
+
+	if task.Priority == "" {
+		task.Priority = priorityNormal
+	}
+	if task.Rank == 0 {
+		rank, err := nextRank(task)
+		if err != nil {
+			return err
+		}
+		task.Rank = rank
+	}
+	// 任务、系列和各关联表在同一事务中写入，任何一步失败都不会留下不完整的任务或没有实例的系列
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	task.SeriesID = nil
+	if task.Recurrence != "" {
+		if err := createSeries(tx, task); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	task.StatusChangedAt = &now
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO tasks (title, description, completed, user_id, due_at, priority, project_id,
+			parent_id, require_children_complete, status, board_rank, status_changed_at, created_by, series_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.Title, task.Description, task.Completed, task.UserID, task.DueAt, task.Priority, task.ProjectID,
+		task.ParentID, task.RequireChildrenComplete, task.Status, task.Rank, task.StatusChangedAt, task.CreatedBy, task.SeriesID,
 	)
 	if err != nil {
 		return err
@@ -121,91 +303,277 @@ func CreateTask(task *Task) error {
 	}
 
 	task.ID = int(id)
+	if err := syncTaskChildren(tx, task); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	task.Version = 1
 	return nil
 }
 
-// GetAllTasks 获取所有任务
-func GetAllTasks() ([]Task, error) {
-	rows, err := db.Query("SELECT id, title, description, completed, user_id, created_at, updated_at FROM tasks")
+// GetTaskByID 根据ID获取任务
+func GetTaskByID(ctx context.Context, id int) (_ *Task, err error) {
+	ctx, span := startDBSpan(ctx, "GetTaskByID", "tasks")
+	defer func() { endDBSpan(span, err) }()
+
+	task, err := scanTask(db.QueryRowContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errTaskNotFound
+		}
 		return nil, err
 	}
-	defer rows.Close()
 
-	var tasks []Task
-	for rows.Next() {
-		var task Task
-		err := rows.Scan(
-			&task.ID, &task.Title, &task.Description, &task.Completed,
-			&task.UserID, &task.CreatedAt, &task.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		tasks = append(tasks, task)
+	tasks := []Task{*task}
+	if err := loadTaskDetails(tasks); err != nil {
+		return nil, err
 	}
+	return &tasks[0], nil
+}
 
-	return tasks, nil
+// TaskFilter 任务列表的过滤条件
+type TaskFilter struct {
+	UserID      int
+	Completed   *bool
+	Query       string // 标题包含的文本
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Priority    string
+	DueFrom     *time.Time
+	DueTo       *time.Time
+	ProjectID   *int  // 0 表示收件箱中的任务
+	TagIDs      []int // 带有其中任一标签，TagMatchAll 时需带有全部标签
+	TagMatchAll bool
+	ParentID    *int // 0 表示顶层任务
+	Status      string
+	CreatedBy   int
+	AssigneeID  int
+	WatcherID   int
+	InvolvedID  int // 负责或关注任务的用户
 }
 
-// GetTaskByID 根据ID获取任务
-func GetTaskByID(id int) (*Task, error) {
-	var task Task
-	err := db.QueryRow(
-		"SELECT id, title, description, completed, user_id, created_at, updated_at FROM tasks WHERE id = ?",
-		id,
-	).Scan(
-		&task.ID, &task.Title, &task.Description, &task.Completed,
-		&task.UserID, &task.CreatedAt, &task.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("task not found")
+// taskSortFields 任务列表允许排序的字段
+var taskSortFields = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"completed":  "completed",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"due_at":     "due_at",
+	"status":     "status",
+	"rank":       "board_rank",
+	"priority":   "FIELD(priority, 'low', 'normal', 'high', 'urgent')",
+}
+
+// ListTasks 分页查询任务，同时返回符合条件的总数
+func ListTasks(ctx context.Context, filter TaskFilter, page Page) (_ []Task, _ int, err error) {
+	ctx, span := startDBSpan(ctx, "ListTasks", "tasks")
+	defer func() { endDBSpan(span, err) }()
+
+	var conds []string
+	var args []interface{}
+	if filter.UserID != 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.CreatedBy != 0 {
+		conds = append(conds, "created_by = ?")
+		args = append(args, filter.CreatedBy)
+	}
+	if filter.AssigneeID != 0 {
+		conds = append(conds, "id IN (SELECT task_id FROM task_assignees WHERE user_id = ?)")
+		args = append(args, filter.AssigneeID)
+	}
+	if filter.WatcherID != 0 {
+		conds = append(conds, "id IN (SELECT task_id FROM task_watchers WHERE user_id = ?)")
+		args = append(args, filter.WatcherID)
+	}
+	if filter.InvolvedID != 0 {
+		conds = append(conds, "(id IN (SELECT task_id FROM task_assignees WHERE user_id = ?) OR "+
+			"id IN (SELECT task_id FROM task_watchers WHERE user_id = ?))")
+		args = append(args, filter.InvolvedID, filter.InvolvedID)
+	}
+	if filter.Completed != nil {
+		conds = append(conds, "completed = ?")
+		args = append(args, *filter.Completed)
+	}
+	if filter.Query != "" {
+		conds = append(conds, "title LIKE ?")
+		args = append(args, likePattern(filter.Query))
+	}
+	if filter.Priority != "" {
+		conds = append(conds, "priority = ?")
+		args = append(args, filter.Priority)
+	}
+	if filter.ProjectID != nil {
+		if *filter.ProjectID == 0 {
+			conds = append(conds, "project_id IS NULL")
+		} else {
+			conds = append(conds, "project_id = ?")
+			args = append(args, *filter.ProjectID)
 		}
-		return nil, err
 	}
+	if filter.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.ParentID != nil {
+		if *filter.ParentID == 0 {
+			conds = append(conds, "parent_id IS NULL")
+		} else {
+			conds = append(conds, "parent_id = ?")
+			args = append(args, *filter.ParentID)
+		}
+	}
+	if len(filter.TagIDs) > 0 {
+		cond := "id IN (SELECT task_id FROM task_tags WHERE tag_id IN (" + placeholders(len(filter.TagIDs)) + ")"
+		for _, id := range filter.TagIDs {
+			args = append(args, id)
+		}
+		if filter.TagMatchAll {
+			cond += " GROUP BY task_id HAVING COUNT(*) = ?"
+			args = append(args, len(filter.TagIDs))
+		}
+		conds = append(conds, cond+")")
+	}
+	for _, c := range []struct {
+		cond  string
+		value *time.Time
+	}{
+		{"created_at >= ?", filter.CreatedFrom},
+		{"created_at < ?", filter.CreatedTo},
+		{"updated_at >= ?", filter.UpdatedFrom},
+		{"updated_at < ?", filter.UpdatedTo},
+		{"due_at >= ?", filter.DueFrom},
+		{"due_at < ?", filter.DueTo},
+	} {
+		if c.value != nil {
+			conds = append(conds, c.cond)
+			args = append(args, *c.value)
+		}
+	}
+	where := whereClause(conds)
 
-	return &task, nil
-}
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tasks"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
 
-// GetTasksByUserID 根据用户ID获取任务
-func GetTasksByUserID(userID int) ([]Task, error) {
-	rows, err := db.Query(
-		"SELECT id, title, description, completed, user_id, created_at, updated_at FROM tasks WHERE user_id = ?",
-		userID,
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+taskColumns+" FROM tasks"+where+" ORDER BY "+page.OrderBy+" LIMIT ? OFFSET ?",
+		append(args, page.Limit, page.Offset)...,
 	)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var tasks []Task
+	tasks := []Task{}
 	for rows.Next() {
-		var task Task
-		err := rows.Scan(
-			&task.ID, &task.Title, &task.Description, &task.Completed,
-			&task.UserID, &task.CreatedAt, &task.UpdatedAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		tasks = append(tasks, task)
+		tasks = append(tasks, *task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
 	}
 
-	return tasks, nil
+	return tasks, total, loadTaskDetails(tasks)
 }
 
-// UpdateTask 更新任务
-func UpdateTask(task *Task) error {
-	_, err := db.Exec(
-		"UPDATE tasks SET title = ?, description = ?, completed = ?, user_id = ? WHERE id = ?",
-		task.Title, task.Description, task.Completed, task.UserID, task.ID,
+// loadTaskDetails 为一组任务填充提醒偏移、标签、负责人、关注者、检查项和进度
+func loadTaskDetails(tasks []Task) error {
+	if err := loadReminderOffsets(tasks); err != nil {
+		return err
+	}
+	if err := loadTaskTags(tasks); err != nil {
+		return err
+	}
+	if err := loadTaskPeople(tasks); err != nil {
+		return err
+	}
+	return loadChecklists(tasks)
+}
+
+// UpdateTask 更新任务，task.Version 为预期的当前版本，版本不一致时返回 errVersionConflict
+func UpdateTask(ctx context.Context, task *Task) (err error) {
+	ctx, span := startDBSpan(ctx, "UpdateTask", "tasks")
+	defer func() { endDBSpan(span, err) }()
+
+	if task.Priority == "" {
+		task.Priority = priorityNormal
+	}
+	// 任务和各关联表在同一事务中更新，失败时整体回滚
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// MySQL 按顺序计算赋值，status_changed_at 需要在 status 之前与旧状态比较
+	result, err := tx.ExecContext(ctx,
+		`UPDATE tasks SET title = ?, description = ?, completed = ?, user_id = ?, due_at = ?, priority = ?,
+			project_id = ?, parent_id = ?, require_children_complete = ?,
+			status_changed_at = IF(status <=> ?, status_changed_at, NOW()), status = ?, board_rank = ?,
+			version = version + 1 WHERE id = ? AND version = ?`,
+		task.Title, task.Description, task.Completed, task.UserID, task.DueAt, task.Priority,
+		task.ProjectID, task.ParentID, task.RequireChildrenComplete,
+		task.Status, task.Status, task.Rank, task.ID, task.Version,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	version := task.Version
+	if err := checkVersionedUpdate(result, &version); err != nil {
+		return err
+	}
+	if err := syncTaskChildren(tx, task); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	task.Version = version
+	return nil
+}
+
+// syncTaskChildren 在调用方的事务中将任务的标签、负责人和关注者、检查项及提醒更新为 task 中的值
+func syncTaskChildren(tx *sql.Tx, task *Task) error {
+	if err := syncTaskTags(tx, task); err != nil {
+		return err
+	}
+	if err := syncTaskPeople(tx, task); err != nil {
+		return err
+	}
+	if err := syncChecklist(tx, task); err != nil {
+		return err
+	}
+	return syncTaskReminders(tx, task)
+}
+
+// checkVersionedUpdate 带版本条件的 UPDATE 未命中任何行说明记录已被其他请求修改
+func checkVersionedUpdate(result sql.Result, version *int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errVersionConflict
+	}
+	*version++
+	return nil
 }
 
 // DeleteTask 删除任务
-func DeleteTask(id int) error {
-	_, err := db.Exec("DELETE FROM tasks WHERE id = ?", id)
+func DeleteTask(ctx context.Context, id int) (err error) {
+	ctx, span := startDBSpan(ctx, "DeleteTask", "tasks")
+	defer func() { endDBSpan(span, err) }()
+
+	_, err = db.ExecContext(ctx, "DELETE FROM tasks WHERE id = ?", id)
 	return err
 }
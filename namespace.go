@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/pkg/logger"
+)
+
+// namespacePermissions 命名空间成员角色在该命名空间内拥有的权限
+var namespacePermissions = map[model.NamespaceRole][]Permission{
+	model.NamespaceRoleAdmin:    {permManageJobs, permOperateJobs, permReadExecutions},
+	model.NamespaceRoleOperator: {permOperateJobs, permReadExecutions},
+	model.NamespaceRoleViewer:   {permReadExecutions},
+}
+
+// namespaceRoleRank 命名空间角色的高低，用于合并系统角色和成员角色
+var namespaceRoleRank = map[model.NamespaceRole]int{
+	model.NamespaceRoleViewer:   1,
+	model.NamespaceRoleOperator: 2,
+	model.NamespaceRoleAdmin:    3,
+}
+
+// globalNamespaceRole 系统角色在所有命名空间中至少拥有的角色：系统管理员视为命名空间管理员，
+// 拥有 permOperateJobs 的用户视为操作员，拥有 permReadExecutions 的用户视为只读成员，其余用户需要成为成员
+func globalNamespaceRole(user *User) model.NamespaceRole {
+	switch {
+	case user.Role == roleAdmin:
+		return model.NamespaceRoleAdmin
+	case hasPermission(user, permOperateJobs):
+		return model.NamespaceRoleOperator
+	case hasPermission(user, permReadExecutions):
+		return model.NamespaceRoleViewer
+	}
+	return ""
+}
+
+// namespaceRole 返回当前用户在命名空间中的角色，取系统角色和成员角色中较高的一个，都没有时返回空
+func namespaceRole(r *http.Request, namespaceID uint) (model.NamespaceRole, error) {
+	user := currentUser(r)
+	if user == nil {
+		return "", nil
+	}
+	role := globalNamespaceRole(user)
+	if role == model.NamespaceRoleAdmin {
+		return role, nil
+	}
+
+	member, err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).GetMember(namespaceID, uint(user.ID))
+	if err != nil {
+		return "", err
+	}
+	if member != nil && namespaceRoleRank[member.Role] > namespaceRoleRank[role] {
+		role = member.Role
+	}
+	return role, nil
+}
+
+// authorizeNamespace 校验当前用户在命名空间内拥有指定权限，失败时写入错误响应
+func authorizeNamespace(w http.ResponseWriter, r *http.Request, namespaceID uint, perm Permission) bool {
+	role, err := namespaceRole(r, namespaceID)
+	if err != nil {
+		logger.Errorf("Error checking namespace membership: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to check namespace membership")
+		return false
+	}
+	for _, p := range namespacePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	sendForbidden(w)
+	return false
+}
+
+// visibleNamespaces 返回当前用户可见的命名空间，系统角色在所有命名空间中生效的用户可见全部
+func visibleNamespaces(r *http.Request) ([]model.Namespace, error) {
+	repo := repository.NewNamespaceRepository(jobDB.WithContext(r.Context()))
+	user := currentUser(r)
+	if globalNamespaceRole(user) != "" {
+		return repo.List()
+	}
+	return repo.ListForUser(uint(user.ID))
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/pkg/logger"
+)
+
+// namespaceMemberRequest 添加成员或修改成员角色的请求体
+type namespaceMemberRequest struct {
+	Role model.NamespaceRole `json:"role"`
+}
+
+// parseNamespaceID 解析路由中的命名空间ID
+func parseNamespaceID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid namespace ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseNamespaceResourceID 解析路由中命名空间下子资源（密钥、日历）的ID
+func parseNamespaceResourceID(w http.ResponseWriter, r *http.Request, key, message string) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)[key], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, message)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// loadNamespace 获取路由中的命名空间，并校验当前用户在其中拥有指定权限
+func loadNamespace(w http.ResponseWriter, r *http.Request, perm Permission) (*model.Namespace, bool) {
+	id, ok := parseNamespaceID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	ns, err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).GetById(id)
+	if err != nil {
+		logger.Errorf("Error getting namespace: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Namespace not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get namespace")
+		}
+		return nil, false
+	}
+	if !authorizeNamespace(w, r, ns.ID, perm) {
+		return nil, false
+	}
+	return ns, true
+}
+
+// createNamespaceHandler 创建命名空间，创建者成为命名空间管理员
+func createNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	var ns model.Namespace
+	if err := json.NewDecoder(r.Body).Decode(&ns); err != nil {
+		logger.Errorf("Error decoding namespace: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := ns.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+	ns.ID = 0
+
+	created, err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).Create(&ns, uint(currentUser(r).ID))
+	if err != nil {
+		logger.Errorf("Error creating namespace: %v", err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Namespace name already exists")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to create namespace")
+		}
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceNamespace, created.ID, nil, created)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// getNamespacesHandler 获取当前用户可见的命名空间
+func getNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	namespaces, err := visibleNamespaces(r)
+	if err != nil {
+		logger.Errorf("Error getting namespaces: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get namespaces")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(namespaces)
+}
+
+// getNamespaceHandler 获取命名空间详情
+func getNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ns)
+}
+
+// updateNamespaceHandler 修改命名空间名称和描述
+func updateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	var req model.Namespace
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding namespace update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	before := *ns
+	ns.Name = req.Name
+	ns.Description = req.Description
+	ns, err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).Update(ns)
+	if err != nil {
+		logger.Errorf("Error updating namespace: %v", err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Namespace name already exists")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to update namespace")
+		}
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceNamespace, ns.ID, &before, ns)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ns)
+}
+
+// deleteNamespaceHandler 删除空的命名空间
+func deleteNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+	if ns.Name == model.DefaultNamespaceName {
+		sendErrorResponse(w, http.StatusConflict, "The default namespace cannot be deleted")
+		return
+	}
+
+	err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).Delete(ns.ID)
+	if err != nil {
+		logger.Errorf("Error deleting namespace: %v", err)
+		if errors.Is(err, repository.ErrNamespaceNotEmpty) {
+			sendErrorResponse(w, http.StatusConflict, "Namespace still contains tasks")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete namespace")
+		}
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceNamespace, ns.ID, ns, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// namespaceMemberAuditID 成员在审计日志中的资源ID，格式为 命名空间ID/用户ID
+func namespaceMemberAuditID(namespaceID, userID uint) string {
+	return fmt.Sprintf("%d/%d", namespaceID, userID)
+}
+
+// getNamespaceMembersHandler 获取命名空间成员
+func getNamespaceMembersHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	members, err := repository.NewNamespaceRepository(jobDB.WithContext(r.Context())).ListMembers(ns.ID)
+	if err != nil {
+		logger.Errorf("Error getting namespace members: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get namespace members")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+// updateNamespaceMemberHandler 添加成员或修改成员角色
+func updateNamespaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req namespaceMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding namespace member: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.Role.Valid() {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+
+	// 检查用户是否存在
+	_, err = GetUserByID(r.Context(), userID)
+	if err != nil {
+		logger.Errorf("Error checking user existence: %v", err)
+		sendError(w, err, "Failed to check user existence")
+		return
+	}
+
+	repo := repository.NewNamespaceRepository(jobDB.WithContext(r.Context()))
+	before, err := repo.GetMember(ns.ID, uint(userID))
+	if err != nil {
+		logger.Errorf("Error getting namespace member: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save namespace member")
+		return
+	}
+	member, err := repo.SaveMember(&model.NamespaceMember{
+		NamespaceID: ns.ID,
+		UserID:      uint(userID),
+		Role:        req.Role,
+	})
+	if err != nil {
+		logger.Errorf("Error saving namespace member: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save namespace member")
+		return
+	}
+	action := auditActionUpdate
+	if before == nil {
+		action = auditActionCreate
+	}
+	recordAudit(r, action, auditResourceNamespaceMember, namespaceMemberAuditID(ns.ID, uint(userID)), before, member)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(member)
+}
+
+// removeNamespaceMemberHandler 移除命名空间成员
+func removeNamespaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	repo := repository.NewNamespaceRepository(jobDB.WithContext(r.Context()))
+	before, err := repo.GetMember(ns.ID, uint(userID))
+	if err != nil {
+		logger.Errorf("Error getting namespace member: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to remove namespace member")
+		return
+	}
+	err = repo.RemoveMember(ns.ID, uint(userID))
+	if err != nil {
+		logger.Errorf("Error removing namespace member: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to remove namespace member")
+		return
+	}
+	if before != nil {
+		recordAudit(r, auditActionDelete, auditResourceNamespaceMember, namespaceMemberAuditID(ns.ID, uint(userID)), before, nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
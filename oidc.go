@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcStateTTL 发起登录到回调之间允许的最长时间
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errOIDCDisabled    = errors.New("oidc login is not configured")
+	errOIDCEmailNeeded = errors.New("id token has no email claim")
+)
+
+// rolePriority 多个分组映射到不同角色时取权限最高的角色
+var rolePriority = map[string]int{roleViewer: 1, roleOperator: 2, roleAdmin: 3}
+
+// oidcSettings OIDC 登录配置。本地调试时可将 OIDC_ISSUER_URL 指向 mock IdP（允许 http）
+type oidcSettings struct {
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string // 公共客户端可为空，仅依靠 PKCE
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	RoleMapping   map[string]string // 分组 -> 角色
+	DefaultRole   string
+}
+
+// oidcSettingsFromEnv 从环境变量读取配置，未配置 OIDC_ISSUER_URL 时返回 errOIDCDisabled
+func oidcSettingsFromEnv() (*oidcSettings, error) {
+	settings := &oidcSettings{
+		IssuerURL:     os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:        strings.Fields(os.Getenv("OIDC_SCOPES")),
+		UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+		GroupsClaim:   os.Getenv("OIDC_GROUPS_CLAIM"),
+		RoleMapping:   make(map[string]string),
+		DefaultRole:   os.Getenv("OIDC_DEFAULT_ROLE"),
+	}
+	if settings.IssuerURL == "" {
+		return nil, errOIDCDisabled
+	}
+	if settings.ClientID == "" || settings.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required")
+	}
+	if len(settings.Scopes) == 0 {
+		settings.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	if settings.UsernameClaim == "" {
+		settings.UsernameClaim = "preferred_username"
+	}
+	if settings.GroupsClaim == "" {
+		settings.GroupsClaim = "groups"
+	}
+	if settings.DefaultRole == "" {
+		settings.DefaultRole = roleViewer
+	}
+	if !validRole(settings.DefaultRole) {
+		return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE %q", settings.DefaultRole)
+	}
+
+	// OIDC_ROLE_MAPPING 格式：group=role,group2=role
+	for _, item := range splitList(os.Getenv("OIDC_ROLE_MAPPING")) {
+		group, role, ok := strings.Cut(item, "=")
+		if !ok || !validRole(role) {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q", item)
+		}
+		settings.RoleMapping[group] = role
+	}
+	return settings, nil
+}
+
+// oidcClient 已完成发现的 OIDC 客户端
+type oidcClient struct {
+	settings *oidcSettings
+	verifier *oidc.IDTokenVerifier
+	oauth2   oauth2.Config
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcInstance *oidcClient
+)
+
+// getOIDCClient 首次使用时通过发现文档初始化客户端；身份提供方不可用时下次请求会重试
+func getOIDCClient(ctx context.Context) (*oidcClient, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcInstance != nil {
+		return oidcInstance, nil
+	}
+
+	settings, err := oidcSettingsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	// 发现文档和 JWKS 的获取不应受单个请求取消的影响
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), settings.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+
+	oidcInstance = &oidcClient{
+		settings: settings,
+		verifier: provider.Verifier(&oidc.Config{ClientID: settings.ClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  settings.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       settings.Scopes,
+		},
+	}
+	return oidcInstance, nil
+}
+
+// oidcIdentity 从 ID 令牌中提取的用户信息
+type oidcIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+// identityFromToken 按配置的声明名称从 ID 令牌中提取用户信息
+func (c *oidcClient) identityFromToken(token *oidc.IDToken) (*oidcIdentity, error) {
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &oidcIdentity{Issuer: token.Issuer, Subject: token.Subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Username, _ = claims[c.settings.UsernameClaim].(string)
+	switch groups := claims[c.settings.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	case string:
+		identity.Groups = splitList(groups)
+	}
+	return identity, nil
+}
+
+// roleForGroups 根据分组映射计算角色，没有匹配的分组时使用默认角色
+func (c *oidcClient) roleForGroups(groups []string) string {
+	role := c.settings.DefaultRole
+	for _, g := range groups {
+		if mapped, ok := c.settings.RoleMapping[g]; ok && rolePriority[mapped] > rolePriority[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// saveOIDCState 保存发起登录时生成的 state、PKCE verifier 和 nonce，并顺带清理过期记录
+func saveOIDCState(state, verifier, nonce string) error {
+	now := time.Now()
+	if _, err := db.Exec("DELETE FROM oidc_login_states WHERE expires_at < ?", now); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"INSERT INTO oidc_login_states (state_hash, code_verifier, nonce, expires_at) VALUES (?, ?, ?, ?)",
+		hashToken(state), verifier, nonce, now.Add(oidcStateTTL),
+	)
+	return err
+}
+
+// consumeOIDCState 取出并删除 state 对应的登录记录，每个 state 只能使用一次
+func consumeOIDCState(state string) (verifier, nonce string, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	hash := hashToken(state)
+	var expiresAt time.Time
+	err = tx.QueryRow(
+		"SELECT code_verifier, nonce, expires_at FROM oidc_login_states WHERE state_hash = ? FOR UPDATE",
+		hash,
+	).Scan(&verifier, &nonce, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", errInvalidToken
+		}
+		return "", "", err
+	}
+	if _, err := tx.Exec("DELETE FROM oidc_login_states WHERE state_hash = ?", hash); err != nil {
+		return "", "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+	if time.Now().After(expiresAt) {
+		return "", "", errInvalidToken
+	}
+	return verifier, nonce, nil
+}
+
+// provisionOIDCUser 返回外部身份对应的本地用户，首次登录时按需创建或按已验证邮箱绑定；
+// 配置了分组映射时每次登录同步角色。第二个返回值表示是否新建了用户
+func (c *oidcClient) provisionOIDCUser(ctx context.Context, identity *oidcIdentity) (*User, bool, error) {
+	var userID int
+	err := db.QueryRow(
+		"SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?",
+		identity.Issuer, identity.Subject,
+	).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	created := false
+	if err == sql.ErrNoRows {
+		userID, created, err = c.linkOrCreateUser(ctx, identity)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if _, err := db.Exec(
+		"UPDATE user_identities SET last_login_at = ? WHERE issuer = ? AND subject = ?",
+		time.Now(), identity.Issuer, identity.Subject,
+	); err != nil {
+		return nil, false, err
+	}
+	if len(c.settings.RoleMapping) > 0 {
+		if _, err := db.Exec("UPDATE users SET role = ? WHERE id = ?", c.roleForGroups(identity.Groups), userID); err != nil {
+			return nil, false, err
+		}
+	}
+	if identity.EmailVerified {
+		if err := MarkEmailVerified(userID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	user, err := GetUserByID(ctx, userID)
+	return user, created, err
+}
+
+// linkOrCreateUser 邮箱已验证且存在同邮箱用户时绑定该用户，否则创建新用户（不设置本地密码）
+func (c *oidcClient) linkOrCreateUser(ctx context.Context, identity *oidcIdentity) (int, bool, error) {
+	if identity.Email == "" {
+		return 0, false, errOIDCEmailNeeded
+	}
+
+	created := false
+	existing, err := GetUserByEmail(identity.Email)
+	var userID int
+	switch {
+	case err == nil && identity.EmailVerified:
+		userID = existing.ID
+	case err == nil:
+		return 0, false, fmt.Errorf("email %s belongs to an existing user but is not verified by the identity provider", identity.Email)
+	case !errors.Is(err, errUserNotFound):
+		return 0, false, err
+	default:
+		username, err := c.uniqueUsername(identity)
+		if err != nil {
+			return 0, false, err
+		}
+		user := &User{
+			Username: username,
+			Email:    identity.Email,
+			Role:     c.roleForGroups(identity.Groups),
+		}
+		if err := CreateUser(ctx, user); err != nil {
+			return 0, false, err
+		}
+		userID = user.ID
+		created = true
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO user_identities (user_id, issuer, subject) VALUES (?, ?, ?)",
+		userID, identity.Issuer, identity.Subject,
+	)
+	return userID, created, err
+}
+
+// uniqueUsername 根据声明生成未被占用的用户名
+func (c *oidcClient) uniqueUsername(identity *oidcIdentity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; i <= 100; i++ {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", candidate).Scan(&count); err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"task-scheduler/pkg/logger"
+)
+
+// oidcClientOrError 获取 OIDC 客户端，失败时写入错误响应
+func oidcClientOrError(w http.ResponseWriter, r *http.Request) (*oidcClient, bool) {
+	client, err := getOIDCClient(r.Context())
+	if err != nil {
+		if errors.Is(err, errOIDCDisabled) {
+			sendErrorResponse(w, http.StatusNotFound, "OIDC login is not configured")
+		} else {
+			logger.Errorf("Error initializing oidc client: %v", err)
+			sendErrorResponse(w, http.StatusBadGateway, "Identity provider is unavailable")
+		}
+		return nil, false
+	}
+	return client, true
+}
+
+// oidcStateCookie 保存 state 哈希的 cookie，回调时与 state 参数比对，确保回调来自发起登录的浏览器
+const oidcStateCookie = "oidc_state"
+
+// setOIDCStateCookie 发起登录时写入 state cookie，有效期与服务端保存的 state 相同
+func (c *oidcClient) setOIDCStateCookie(w http.ResponseWriter, r *http.Request, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    hashToken(state),
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(oidcStateTTL / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(c.settings.RedirectURL, "https://"),
+		// 身份提供方重定向回来是跨站的顶层导航，Strict 会丢掉 cookie
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearOIDCStateCookie 回调处理后删除 state cookie
+func clearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1, HttpOnly: true})
+}
+
+// oidcStateMatchesCookie 判断回调中的 state 是否与当前浏览器发起登录时写入的 cookie 一致
+func oidcStateMatchesCookie(r *http.Request, state string) bool {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(hashToken(state))) == 1
+}
+
+// oidcLoginHandler 发起授权码 + PKCE 登录，默认重定向到身份提供方；redirect=false 时返回授权地址
+func oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := oidcClientOrError(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := randomToken(16)
+	if err != nil {
+		logger.Errorf("Error generating oidc state: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		logger.Errorf("Error generating oidc nonce: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	if err := saveOIDCState(state, verifier, nonce); err != nil {
+		logger.Errorf("Error saving oidc state: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	client.setOIDCStateCookie(w, r, state)
+
+	authURL := client.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	if r.URL.Query().Get("redirect") == "false" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"authorization_url": authURL})
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler 处理身份提供方的回调：换取并校验 ID 令牌、按需创建用户、签发本系统令牌
+func oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := oidcClientOrError(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		logger.Warnf("OIDC login rejected by identity provider: %s %s", e, query.Get("error_description"))
+		sendErrorResponse(w, http.StatusUnauthorized, "Login was rejected by the identity provider")
+		return
+	}
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Code and state are required")
+		return
+	}
+	// state 必须与发起登录的浏览器绑定，防止攻击者把自己的授权码注入受害者的会话（登录 CSRF）；
+	// 不匹配时不消费 state，避免被用来作废他人正在进行的登录
+	if !oidcStateMatchesCookie(r, state) {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+	clearOIDCStateCookie(w)
+
+	verifier, nonce, err := consumeOIDCState(state)
+	if err != nil {
+		if err == errInvalidToken {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired login state")
+		} else {
+			logger.Errorf("Error loading oidc state: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to complete login")
+		}
+		return
+	}
+
+	token, err := client.oauth2.Exchange(r.Context(), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		logger.Errorf("Error exchanging oidc code: %v", err)
+		sendErrorResponse(w, http.StatusUnauthorized, "Failed to exchange authorization code")
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		sendErrorResponse(w, http.StatusUnauthorized, "Identity provider did not return an ID token")
+		return
+	}
+
+	// 校验签名（JWKS）、issuer、audience 和过期时间
+	idToken, err := client.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		logger.Warnf("Invalid oidc id token: %v", err)
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid ID token")
+		return
+	}
+	if idToken.Nonce != nonce {
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid ID token")
+		return
+	}
+
+	identity, err := client.identityFromToken(idToken)
+	if err != nil {
+		logger.Errorf("Error reading oidc claims: %v", err)
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid ID token")
+		return
+	}
+
+	user, created, err := client.provisionOIDCUser(r.Context(), identity)
+	if err != nil {
+		logger.Errorf("Error provisioning oidc user: %v", err)
+		if errors.Is(err, errOIDCEmailNeeded) {
+			sendErrorResponse(w, http.StatusForbidden, "The identity provider did not supply an email address")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to complete login")
+		}
+		return
+	}
+	if created {
+		recordAudit(r, auditActionCreate, auditResourceUser, user.ID, nil, user)
+	}
+	if user.DisabledAt != nil {
+		sendErrorResponse(w, http.StatusForbidden, "Account is disabled")
+		return
+	}
+
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		logger.Errorf("Error issuing tokens: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
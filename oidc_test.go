@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const mockIdPKeyID = "test-key"
+
+// mockIdP 模拟身份提供方，提供发现文档、JWKS 和令牌端点
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{} // 令牌端点签发的 ID 令牌中的额外声明
+	codes  map[string]string      // 授权码 -> 期望的 PKCE verifier
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	idp := &mockIdP{key: key, claims: map[string]interface{}{}, codes: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": mockIdPKeyID,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		want, ok := idp.codes[r.PostForm.Get("code")]
+		if !ok || r.PostForm.Get("code_verifier") != want {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		delete(idp.codes, r.PostForm.Get("code"))
+
+		claims := map[string]interface{}{
+			"iss": idp.server.URL,
+			"aud": "scheduler",
+			"sub": "user-123",
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.sign(t, claims),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// sign 以 RS256 签发 JWT
+func (idp *mockIdP) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": mockIdPKeyID})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Marshal(claims) error = %v", err)
+	}
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15() error = %v", err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// useMockIdP 将 OIDC 配置指向模拟身份提供方，测试结束后重置客户端
+func useMockIdP(t *testing.T, idp *mockIdP) *oidcClient {
+	t.Helper()
+	t.Setenv("OIDC_ISSUER_URL", idp.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", "scheduler")
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_REDIRECT_URL", "https://scheduler.example.com/api/auth/oidc/callback")
+	t.Setenv("OIDC_ROLE_MAPPING", "ops=operator,platform=admin")
+
+	oidcMu.Lock()
+	oidcInstance = nil
+	oidcMu.Unlock()
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcInstance = nil
+		oidcMu.Unlock()
+	})
+
+	client, err := getOIDCClient(context.Background())
+	if err != nil {
+		t.Fatalf("getOIDCClient() error = %v", err)
+	}
+	return client
+}
+
+func TestOIDCCodeExchangeWithMockIdP(t *testing.T) {
+	idp := newMockIdP(t)
+	client := useMockIdP(t, idp)
+	ctx := context.Background()
+
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := url.Parse(client.oauth2.AuthCodeURL("state-1", oidc.Nonce("nonce-1"), oauth2.S256ChallengeOption(verifier)))
+	if err != nil {
+		t.Fatalf("AuthCodeURL() is not a URL: %v", err)
+	}
+	if got := authURL.Scheme + "://" + authURL.Host + authURL.Path; got != idp.server.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %q, want the discovered one", got)
+	}
+	if authURL.Query().Get("code_challenge_method") != "S256" || authURL.Query().Get("nonce") != "nonce-1" {
+		t.Errorf("authorization URL %q lacks the PKCE challenge or nonce", authURL)
+	}
+
+	idp.codes["code-1"] = verifier
+	idp.claims = map[string]interface{}{
+		"nonce":              "nonce-1",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"groups":             []string{"ops", "platform"},
+	}
+	token, err := client.oauth2.Exchange(ctx, "code-1", oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := client.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if idToken.Nonce != "nonce-1" {
+		t.Errorf("nonce = %q, want nonce-1", idToken.Nonce)
+	}
+
+	identity, err := client.identityFromToken(idToken)
+	if err != nil {
+		t.Fatalf("identityFromToken() error = %v", err)
+	}
+	if identity.Issuer != idp.server.URL || identity.Subject != "user-123" || identity.Email != "alice@example.com" ||
+		!identity.EmailVerified || identity.Username != "alice" {
+		t.Errorf("identity = %+v, want the claims issued by the mock IdP", identity)
+	}
+	if role := client.roleForGroups(identity.Groups); role != roleAdmin {
+		t.Errorf("roleForGroups(%v) = %q, want %q", identity.Groups, role, roleAdmin)
+	}
+}
+
+func TestOIDCRejectsWrongVerifierAndForeignTokens(t *testing.T) {
+	idp := newMockIdP(t)
+	client := useMockIdP(t, idp)
+	ctx := context.Background()
+
+	idp.codes["code-1"] = oauth2.GenerateVerifier()
+	if _, err := client.oauth2.Exchange(ctx, "code-1", oauth2.VerifierOption(oauth2.GenerateVerifier())); err == nil {
+		t.Error("Exchange() with the wrong PKCE verifier should fail")
+	}
+
+	other := newMockIdP(t)
+	foreign := other.sign(t, map[string]interface{}{
+		"iss": idp.server.URL,
+		"aud": "scheduler",
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := client.verifier.Verify(ctx, foreign); err == nil {
+		t.Error("Verify() should reject a token signed by another key")
+	}
+
+	wrongAudience := idp.sign(t, map[string]interface{}{
+		"iss": idp.server.URL,
+		"aud": "someone-else",
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := client.verifier.Verify(ctx, wrongAudience); err == nil {
+		t.Error("Verify() should reject a token issued to another client")
+	}
+}
+
+func TestOIDCStateCookie(t *testing.T) {
+	idp := newMockIdP(t)
+	client := useMockIdP(t, idp)
+
+	rec := httptest.NewRecorder()
+	client.setOIDCStateCookie(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil), "state-1")
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("login set %d cookies, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Value == "state-1" {
+		t.Errorf("state cookie = %+v, want HttpOnly, Secure, SameSite=Lax and a hashed value", cookie)
+	}
+
+	callback := func(state string, withCookie bool) bool {
+		r := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code=c&state="+state, nil)
+		if withCookie {
+			r.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+		return oidcStateMatchesCookie(r, state)
+	}
+	if !callback("state-1", true) {
+		t.Error("callback from the browser that started the login was rejected")
+	}
+	if callback("state-1", false) {
+		t.Error("callback without the state cookie was accepted")
+	}
+	if callback("state-2", true) {
+		t.Error("callback with another login's state was accepted")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Page 列表接口的分页与排序参数
+type Page struct {
+	Limit  int
+	Offset int
+	// OrderBy 已按白名单转换为列名的排序子句，如 "created_at DESC, id DESC"
+	OrderBy string
+}
+
+// parsePage 解析 limit、offset 和 sort 参数。sort 为逗号分隔的字段列表，字段前加 "-" 表示降序，
+// 只接受 sortable 中列出的字段（参数名 -> 列名）；最后总是按 id 排序以保证分页稳定
+func parsePage(r *http.Request, sortable map[string]string, defaultSort string) (Page, string) {
+	query := r.URL.Query()
+	page := Page{Limit: defaultPageLimit}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return page, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit)
+		}
+		page.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, "Invalid offset"
+		}
+		page.Offset = n
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = defaultSort
+	}
+	var order []string
+	idOrder := "id ASC"
+	for _, field := range strings.Split(sort, ",") {
+		dir := "ASC"
+		if strings.HasPrefix(field, "-") {
+			field, dir = field[1:], "DESC"
+		}
+		column, ok := sortable[field]
+		if !ok {
+			return page, "Unsupported sort field: " + field
+		}
+		if column == "id" {
+			idOrder = "id " + dir
+			continue
+		}
+		order = append(order, column+" "+dir)
+	}
+	page.OrderBy = strings.Join(append(order, idOrder), ", ")
+	return page, ""
+}
+
+// parseTimeRange 解析 <name>_from 和 <name>_to 两个 RFC 3339 时间参数
+func parseTimeRange(r *http.Request, name string) (from, to *time.Time, msg string) {
+	query := r.URL.Query()
+	for suffix, dst := range map[string]**time.Time{"_from": &from, "_to": &to} {
+		if v := query.Get(name + suffix); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, nil, name + suffix + " must be an RFC 3339 timestamp"
+			}
+			*dst = &t
+		}
+	}
+	return from, to, ""
+}
+
+// likePattern 转义 LIKE 通配符，返回子串匹配的模式
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+// whereClause 拼接查询条件，没有条件时返回空串
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// setPageHeaders 通过 X-Total-Count 返回总数，并以 Link 头给出上一页、下一页地址
+func setPageHeaders(w http.ResponseWriter, r *http.Request, page Page, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	link := func(offset int, rel string) string {
+		u := *r.URL
+		query := u.Query()
+		query.Set("limit", strconv.Itoa(page.Limit))
+		query.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+	var links []string
+	if page.Offset+page.Limit < total {
+		links = append(links, link(page.Offset+page.Limit, "next"))
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
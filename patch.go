@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag 由版本号生成强 ETag
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// checkIfMatch 请求带 If-Match 时，要求其中之一与当前版本一致，否则返回 412
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		// If-Match 使用强比较，弱 ETag 不会匹配
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+	sendError(w, errVersionConflict, "")
+	return false
+}
+
+// applyMergePatch 按 JSON Merge Patch（RFC 7396）将 patch 合并到 original 的 JSON 表示上，
+// 结果解码到 result 中；patch 中为 null 的字段会被清空
+func applyMergePatch(original interface{}, patch []byte, result interface{}) error {
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return errors.New("merge patch must be a JSON object")
+	}
+
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	target, err := decodeJSON(doc)
+	if err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(target, p))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, result)
+}
+
+// mergePatch RFC 7396 中的 MergePatch 算法
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// decodeJSON 解码任意 JSON，数字保留为 json.Number 以免大整数丢失精度
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	for name, tc := range map[string]struct {
+		target, patch, want string
+	}{
+		"adds and replaces fields": {`{"a":1,"b":"x"}`, `{"b":"y","c":true}`, `{"a":1,"b":"y","c":true}`},
+		"null clears a field":      {`{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		"null on a missing field":  {`{"a":1}`, `{"z":null}`, `{"a":1}`},
+		"nested objects merge":     {`{"o":{"x":1,"y":2}}`, `{"o":{"y":3,"z":null}}`, `{"o":{"x":1,"y":3}}`},
+		"object replaces scalar":   {`{"o":"s"}`, `{"o":{"x":1}}`, `{"o":{"x":1}}`},
+		"arrays are replaced":      {`{"l":[1,2,3]}`, `{"l":[4]}`, `{"l":[4]}`},
+		"empty array clears list":  {`{"l":[1,2]}`, `{"l":[]}`, `{"l":[]}`},
+		"null inside array kept":   {`{"l":[1]}`, `{"l":[null,{"a":null}]}`, `{"l":[null,{"a":null}]}`},
+		"large integers kept":      {`{"n":1}`, `{"n":9007199254740993}`, `{"n":9007199254740993}`},
+	} {
+		target, err := decodeJSON([]byte(tc.target))
+		if err != nil {
+			t.Fatalf("%s: decode target: %v", name, err)
+		}
+		patch, err := decodeJSON([]byte(tc.patch))
+		if err != nil {
+			t.Fatalf("%s: decode patch: %v", name, err)
+		}
+		got, err := json.Marshal(mergePatch(target, patch))
+		if err != nil {
+			t.Fatalf("%s: encode result: %v", name, err)
+		}
+		want, _ := decodeJSON([]byte(tc.want))
+		if gotValue, _ := decodeJSON(got); !reflect.DeepEqual(gotValue, want) {
+			t.Errorf("%s: mergePatch() = %s, want %s", name, got, tc.want)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	type item struct {
+		Title  string            `json:"title"`
+		DueAt  *string           `json:"due_at"`
+		Tags   []int             `json:"tags"`
+		Labels map[string]string `json:"labels"`
+	}
+	due := "2026-03-01T09:00:00Z"
+	original := item{Title: "draft", DueAt: &due, Tags: []int{1, 2}, Labels: map[string]string{"a": "1", "b": "2"}}
+
+	var got item
+	patch := `{"title":"final","due_at":null,"tags":[3],"labels":{"b":null,"c":"3"}}`
+	if err := applyMergePatch(original, []byte(patch), &got); err != nil {
+		t.Fatalf("applyMergePatch() error = %v", err)
+	}
+	want := item{Title: "final", Tags: []int{3}, Labels: map[string]string{"a": "1", "c": "3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyMergePatch() = %+v, want %+v", got, want)
+	}
+	if original.Title != "draft" || len(original.Tags) != 2 || len(original.Labels) != 2 {
+		t.Errorf("applyMergePatch() modified the original: %+v", original)
+	}
+
+	for _, patch := range []string{`[]`, `"title"`, `null`, `{"title":`, ``} {
+		var result item
+		if err := applyMergePatch(original, []byte(patch), &result); err == nil {
+			t.Errorf("applyMergePatch(%q) should fail", patch)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	for header, ok := range map[string]bool{
+		"":              true,
+		"*":             true,
+		`"3"`:           true,
+		`"1", "3"`:      true,
+		` "2" ,"3" `:    true,
+		`"2"`:           false,
+		`W/"3"`:         false,
+		`"1", W/"3"`:    false,
+		`3`:             false,
+		`"1", "2", "4"`: false,
+	} {
+		r := httptest.NewRequest(http.MethodPatch, "/api/todos/1", nil)
+		if header != "" {
+			r.Header.Set("If-Match", header)
+		}
+		rec := httptest.NewRecorder()
+		if got := checkIfMatch(rec, r, 3); got != ok {
+			t.Errorf("checkIfMatch(%q) = %v, want %v", header, got, ok)
+		}
+		if !ok && rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("checkIfMatch(%q): status = %d, want %d", header, rec.Code, http.StatusPreconditionFailed)
+		}
+	}
+}
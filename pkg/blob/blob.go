@@ -0,0 +1,147 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 支持的存储方式
+const (
+	DriverFS = "fs"
+	DriverS3 = "s3"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Store 对象存储，key 为以 / 分隔的相对路径
+type Store interface {
+	// Put 写入对象，size 未知时为 -1
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// Config 对象存储配置
+type Config struct {
+	Driver string // fs | s3
+	Dir    string // fs 方式的根目录
+
+	// S3 兼容存储（如 MinIO）
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// ConfigFromEnv 从环境变量读取配置
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:    getEnv("BLOB_DRIVER", DriverFS),
+		Dir:       getEnv("BLOB_FS_DIR", "attachments"),
+		Endpoint:  os.Getenv("BLOB_S3_ENDPOINT"),
+		Region:    getEnv("BLOB_S3_REGION", "us-east-1"),
+		Bucket:    os.Getenv("BLOB_S3_BUCKET"),
+		AccessKey: os.Getenv("BLOB_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("BLOB_S3_SECRET_KEY"),
+		UseSSL:    getEnv("BLOB_S3_USE_SSL", "true") != "false",
+	}
+}
+
+// NewStore 根据配置创建对象存储
+func NewStore(cfg Config) (Store, error) {
+	switch cfg.Driver {
+	case "", DriverFS:
+		return &FSStore{Dir: cfg.Dir}, nil
+	case DriverS3:
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("BLOB_S3_ENDPOINT and BLOB_S3_BUCKET are required for the s3 driver")
+		}
+		return NewS3Store(cfg)
+	default:
+		return nil, fmt.Errorf("unknown blob driver %q", cfg.Driver)
+	}
+}
+
+// FSStore 将对象保存在本地目录中，每个对象一个文件
+type FSStore struct {
+	Dir string
+}
+
+// path 将 key 转换为根目录下的文件路径，拒绝跳出根目录的 key
+func (s *FSStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+// Put 先写入临时文件再重命名，读取方不会看到写了一半的对象
+func (s *FSStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get 打开对象文件
+func (s *FSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete 删除对象文件
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,149 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 支持的发送方式
+const (
+	DriverSMTP   = "smtp"
+	DriverOutbox = "outbox"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender 邮件发送器
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config 邮件配置
+type Config struct {
+	Driver    string // smtp | outbox
+	From      string
+	SMTPHost  string
+	SMTPPort  string
+	Username  string
+	Password  string
+	OutboxDir string // outbox 方式的输出目录
+}
+
+// ConfigFromEnv 从环境变量读取配置
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:    getEnv("MAIL_DRIVER", DriverOutbox),
+		From:      getEnv("MAIL_FROM", "no-reply@localhost"),
+		SMTPHost:  os.Getenv("MAIL_SMTP_HOST"),
+		SMTPPort:  getEnv("MAIL_SMTP_PORT", "587"),
+		Username:  os.Getenv("MAIL_SMTP_USERNAME"),
+		Password:  os.Getenv("MAIL_SMTP_PASSWORD"),
+		OutboxDir: getEnv("MAIL_OUTBOX_DIR", "outbox"),
+	}
+}
+
+// NewSender 根据配置创建发送器
+func NewSender(cfg Config) (Sender, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("MAIL_SMTP_HOST is required for the smtp driver")
+		}
+		return &SMTPSender{
+			Addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+			Host:     cfg.SMTPHost,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+		}, nil
+	case "", DriverOutbox:
+		return &OutboxSender{Dir: cfg.OutboxDir, From: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// SMTPSender 通过 SMTP 服务器发送邮件，服务器支持时使用 STARTTLS
+type SMTPSender struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send 发送邮件
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	// net/smtp 不支持 context，放到协程中执行以便按 ctx 超时返回
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, render(s.From, msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OutboxSender 将邮件写入本地目录（每封一个 .eml 文件），用于开发和测试
+type OutboxSender struct {
+	Dir  string
+	From string
+}
+
+// Send 写入邮件文件
+func (s *OutboxSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create outbox: %w", err)
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(s.Dir, name), render(s.From, msg), 0o600)
+}
+
+// render 生成 RFC 5322 格式的邮件内容
+func render(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + sanitizeHeader(msg.To) + "\r\n")
+	b.WriteString("Subject: " + sanitizeHeader(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// sanitizeHeader 去掉换行，防止邮件头注入
+func sanitizeHeader(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
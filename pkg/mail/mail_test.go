@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutboxSenderWritesMessage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	sender := &OutboxSender{Dir: dir, From: "no-reply@example.com"}
+
+	err := sender.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Reset your password",
+		Body:    "Hi alice,\n\nhttp://localhost:8080/reset-password?token=abc\n",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("outbox files = %v (err %v), want exactly one .eml", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{
+		"From: no-reply@example.com\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"\r\n\r\nHi alice,\r\n\r\nhttp://localhost:8080/reset-password?token=abc\r\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("message does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestOutboxSenderStripsHeaderInjection(t *testing.T) {
+	dir := t.TempDir()
+	sender := &OutboxSender{Dir: dir, From: "no-reply@example.com"}
+
+	err := sender.Send(context.Background(), Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "Hello\nX-Injected: yes",
+		Body:    "body",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("outbox files = %v, want exactly one .eml", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	headers, _, _ := strings.Cut(string(data), "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Injected:") {
+			t.Errorf("injected header line %q", line)
+		}
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	sender, err := NewSender(Config{Driver: DriverOutbox, OutboxDir: "out", From: "a@example.com"})
+	if err != nil {
+		t.Fatalf("NewSender(outbox) error = %v", err)
+	}
+	if _, ok := sender.(*OutboxSender); !ok {
+		t.Errorf("NewSender(outbox) = %T, want *OutboxSender", sender)
+	}
+
+	if _, err := NewSender(Config{Driver: DriverSMTP}); err == nil {
+		t.Error("NewSender(smtp) without a host should fail")
+	}
+	if _, err := NewSender(Config{Driver: "carrier-pigeon"}); err == nil {
+		t.Error("NewSender() with an unknown driver should fail")
+	}
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceEnv(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	env := TraceEnv(ctx)
+	if len(env) != 1 {
+		t.Fatalf("TraceEnv() = %v, want a single TRACEPARENT entry", env)
+	}
+	sc := span.SpanContext()
+	want := "TRACEPARENT=00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if env[0] != want {
+		t.Errorf("TraceEnv() = %q, want %q", env[0], want)
+	}
+}
+
+func TestTraceEnvWithoutSpan(t *testing.T) {
+	if env := TraceEnv(context.Background()); len(env) != 0 {
+		t.Errorf("TraceEnv() without a span = %v, want none", env)
+	}
+}
+
+func TestInitTracerRejectsUnknownExporter(t *testing.T) {
+	_, err := InitTracer(context.Background(), Config{Exporter: "zipkin"})
+	if err == nil || !strings.Contains(err.Error(), "zipkin") {
+		t.Errorf("InitTracer() error = %v, want unknown exporter error", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Package validation 提供声明式的字段校验，收集所有不合法的字段而不是遇到第一个错误就返回
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 机器可读的错误码
+const (
+	CodeRequired     = "required"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeInvalid      = "invalid_format"
+	CodeInvalidEmail = "invalid_email"
+	CodeWeakPassword = "weak_password"
+	CodeInvalidCron  = "invalid_cron"
+	CodeOutOfRange   = "out_of_range"
+	CodeNotAllowed   = "not_allowed"
+)
+
+// ErrValidation 校验失败的错误类别，errors.Is(err, ErrValidation) 对 Errors 成立
+var ErrValidation = errors.New("validation failed")
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors 一次校验中收集到的全部字段错误
+type Errors []FieldError
+
+// Error 实现 error 接口
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Is 使 Errors 归入 ErrValidation 类别
+func (e Errors) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Rule 字符串字段的校验规则，Test 返回 false 时记录 Code 和 Message
+type Rule struct {
+	Code    string
+	Message string
+	Test    func(string) bool
+	// always 为 true 时空值也会执行校验，只有 Required 使用
+	always bool
+}
+
+// Validator 收集字段错误，每个字段只记录第一条失败的规则
+type Validator struct {
+	errs Errors
+}
+
+// String 依次对字段值执行规则；除 Required 外，空值视为未提供而跳过
+func (v *Validator) String(field, value string, rules ...Rule) {
+	for _, rule := range rules {
+		if value == "" && !rule.always {
+			continue
+		}
+		if !rule.Test(value) {
+			v.Add(field, rule.Code, rule.Message)
+			return
+		}
+	}
+}
+
+// Check 条件不成立时记录错误，用于非字符串字段
+func (v *Validator) Check(ok bool, field, code, message string) {
+	if !ok {
+		v.Add(field, code, message)
+	}
+}
+
+// Add 直接记录一条错误
+func (v *Validator) Add(field, code, message string) {
+	v.errs = append(v.errs, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err 没有错误时返回 nil，否则返回 Errors
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Required 值不能为空（仅包含空白也视为空）
+var Required = Rule{
+	Code:    CodeRequired,
+	Message: "is required",
+	Test:    func(s string) bool { return strings.TrimSpace(s) != "" },
+	always:  true,
+}
+
+// MinLength 至少 n 个字符
+func MinLength(n int) Rule {
+	return Rule{
+		Code:    CodeTooShort,
+		Message: fmt.Sprintf("must be at least %d characters", n),
+		Test:    func(s string) bool { return utf8.RuneCountInString(s) >= n },
+	}
+}
+
+// MaxLength 最多 n 个字符
+func MaxLength(n int) Rule {
+	return Rule{
+		Code:    CodeTooLong,
+		Message: fmt.Sprintf("must be at most %d characters", n),
+		Test:    func(s string) bool { return utf8.RuneCountInString(s) <= n },
+	}
+}
+
+// Matches 必须匹配正则表达式，description 描述允许的格式
+func Matches(re *regexp.Regexp, description string) Rule {
+	return Rule{
+		Code:    CodeInvalid,
+		Message: "must contain only " + description,
+		Test:    re.MatchString,
+	}
+}
+
+// OneOf 必须是给定值之一
+func OneOf(values ...string) Rule {
+	return Rule{
+		Code:    CodeNotAllowed,
+		Message: "must be one of " + strings.Join(values, ", "),
+		Test: func(s string) bool {
+			for _, v := range values {
+				if s == v {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Email 必须是不带显示名的邮箱地址
+var Email = Rule{
+	Code:    CodeInvalidEmail,
+	Message: "must be a valid email address",
+	Test: func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+	},
+}
+
+// Password 至少 8 个字符，且同时包含字母和数字
+var Password = Rule{
+	Code:    CodeWeakPassword,
+	Message: "must be at least 8 characters and contain both letters and digits",
+	Test: func(s string) bool {
+		var letter, digit bool
+		for _, r := range s {
+			switch {
+			case unicode.IsLetter(r):
+				letter = true
+			case unicode.IsDigit(r):
+				digit = true
+			}
+		}
+		return utf8.RuneCountInString(s) >= 8 && letter && digit
+	},
+}
+
+// cronParser 与调度器一致：标准 5 段表达式，支持 @every 等描述符
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Cron 必须是调度器能够解析的 cron 表达式
+var Cron = Rule{
+	Code:    CodeInvalidCron,
+	Message: "must be a valid 5-field cron expression",
+	Test: func(s string) bool {
+		_, err := cronParser.Parse(s)
+		return err == nil
+	},
+}
+
+// TimeOfDay 必须是 HH:MM 格式的时间
+var TimeOfDay = Rule{
+	Code:    CodeInvalid,
+	Message: "must be a time of day in HH:MM format",
+	Test: func(s string) bool {
+		_, err := time.Parse("15:04", s)
+		return err == nil
+	},
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+)
+
+// 用户角色
+const (
+	roleAdmin    = "admin"
+	roleOperator = "operator"
+	roleViewer   = "viewer"
+)
+
+// Permission 资源操作权限
+type Permission string
+
+const (
+	permManageUsers    Permission = "users:manage"     // 管理所有用户及其角色
+	permManageAllTodos Permission = "todos:manage_all" // 查看和修改其他用户的待办任务
+	permManageJobs     Permission = "jobs:manage"      // 管理 webhook、保留策略等调度配置
+	permOperateJobs    Permission = "jobs:operate"     // 启动、停止、立即执行定时任务及清理执行记录
+	permReadExecutions Permission = "executions:read"  // 查看执行记录与统计
+	permReadAudit      Permission = "audit:read"       // 查询和导出审计日志
+)
+
+// rolePermissions 各角色拥有的权限
+var rolePermissions = map[string][]Permission{
+	roleAdmin:    {permManageUsers, permManageAllTodos, permManageJobs, permOperateJobs, permReadExecutions, permReadAudit},
+	roleOperator: {permOperateJobs, permReadExecutions},
+	roleViewer:   {permReadExecutions},
+}
+
+// validRole 判断角色名称是否合法
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// hasPermission 判断用户的角色是否拥有指定权限
+func hasPermission(user *User, perm Permission) bool {
+	if user == nil {
+		return false
+	}
+	for _, p := range rolePermissions[user.Role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccessUser 判断用户能否访问目标用户的资源（本人或拥有权限）
+func canAccessUser(user *User, ownerID int, perm Permission) bool {
+	return user != nil && (user.ID == ownerID || hasPermission(user, perm))
+}
+
+// requirePermission 要求当前用户拥有指定权限才能调用处理函数
+func requirePermission(perm Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasPermission(currentUser(r), perm) {
+			sendForbidden(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// sendForbidden 返回统一的权限不足响应
+func sendForbidden(w http.ResponseWriter) {
+	sendError(w, errPermissionDenied, "")
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"task-scheduler/pkg/logger"
+	"task-scheduler/pkg/mail"
+)
+
+const (
+	// 每个任务最多的提醒数和最早的提醒时间
+	maxTaskReminders      = 5
+	maxReminderOffsetMins = 30 * 24 * 60
+
+	reminderCheckInterval = time.Minute
+	reminderBatchSize     = 500
+	reminderQueueSize     = 256
+	reminderSendTimeout   = 30 * time.Second
+
+	// 发送失败的提醒最多尝试的次数，第 n 次失败后等待 n 倍的重试间隔
+	maxReminderAttempts = 5
+	reminderRetryDelay  = 5 * time.Minute
+	// reminderClaimTTL 领取提醒后的占用时间。投递协程取出提醒时会重新占用，
+	// 因此只需覆盖一次发送的时间；在通道中等待过久而过期的提醒由 refreshReminderClaim 丢弃
+	reminderClaimTTL = 10 * time.Minute
+)
+
+// ReminderNotification 一条到期提醒
+type ReminderNotification struct {
+	TaskID        int
+	Title         string
+	DueAt         time.Time
+	OffsetMinutes int
+	UserID        int
+	Username      string
+	Email         string
+}
+
+// Notifier 提醒的投递方式
+type Notifier interface {
+	Notify(ctx context.Context, n ReminderNotification) error
+}
+
+// mailNotifier 通过账号邮件发送器投递提醒
+type mailNotifier struct{}
+
+// Notify 发送提醒邮件
+func (mailNotifier) Notify(ctx context.Context, n ReminderNotification) error {
+	return mailSender.Send(ctx, mail.Message{
+		To:      n.Email,
+		Subject: "Reminder: " + n.Title,
+		Body: fmt.Sprintf("Hi %s,\n\nYour task \"%s\" is due at %s.\n\n%s/tasks/%d\n",
+			n.Username, n.Title, n.DueAt.UTC().Format(time.RFC1123), appBaseURL(), n.TaskID),
+	})
+}
+
+// queuedReminder 通知通道中的一条提醒，attempt 为本次是第几次发送
+type queuedReminder struct {
+	id      int
+	attempt int
+	n       ReminderNotification
+}
+
+var (
+	// reminderNotifier 提醒的投递方式，默认发送邮件
+	reminderNotifier Notifier = mailNotifier{}
+	// reminderQueue 调度器作业与投递协程之间的通知通道
+	reminderQueue = make(chan queuedReminder, reminderQueueSize)
+)
+
+// startReminderWorker 启动投递协程，逐条发送通知通道中的提醒
+func startReminderWorker() {
+	go func() {
+		for q := range reminderQueue {
+			if !refreshReminderClaim(q) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), reminderSendTimeout)
+			err := reminderNotifier.Notify(ctx, q.n)
+			cancel()
+			finishReminder(q, err)
+		}
+	}()
+}
+
+// refreshReminderClaim 发送前重新占用提醒。提醒在通道中等待期间占用可能已经过期并被再次领取
+// （领取会增加 attempts），或已由其他实例发送，这时返回 false，本条不再发送
+func refreshReminderClaim(q queuedReminder) bool {
+	result, err := db.Exec(
+		"UPDATE task_reminders SET claimed_until = ? WHERE id = ? AND sent_at IS NULL AND attempts = ?",
+		time.Now().Add(reminderClaimTTL), q.id, q.attempt,
+	)
+	if err != nil {
+		logger.Errorf("Failed to refresh claim of reminder %d: %v", q.id, err)
+		return false
+	}
+	n, err := result.RowsAffected()
+	return err == nil && n > 0
+}
+
+// finishReminder 发送成功后标记提醒已发送；失败时按次数推迟重试，达到上限后不再发送
+func finishReminder(q queuedReminder, sendErr error) {
+	if sendErr == nil {
+		if _, err := db.Exec("UPDATE task_reminders SET sent_at = ?, claimed_until = NULL WHERE id = ?", time.Now(), q.id); err != nil {
+			logger.Errorf("Failed to mark reminder %d as sent: %v", q.id, err)
+		}
+		return
+	}
+
+	if q.attempt >= maxReminderAttempts {
+		logger.Errorf("Giving up reminder for task %d to user %d after %d attempts: %v", q.n.TaskID, q.n.UserID, q.attempt, sendErr)
+		return
+	}
+	logger.Warnf("Failed to send reminder for task %d to user %d (attempt %d), will retry: %v", q.n.TaskID, q.n.UserID, q.attempt, sendErr)
+	retryAt := time.Now().Add(time.Duration(q.attempt) * reminderRetryDelay)
+	if _, err := db.Exec("UPDATE task_reminders SET claimed_until = ? WHERE id = ?", retryAt, q.id); err != nil {
+		logger.Errorf("Failed to schedule retry of reminder %d: %v", q.id, err)
+	}
+}
+
+// fireDueReminders 调度器维护作业：将到达提醒时间且任务未完成的提醒放入通知通道。
+// 提醒在发送成功后才标记为已发送；发送期间由 claimed_until 占用，占用过期（实例退出或等待重试结束）后可以再次领取
+func fireDueReminders() {
+	now := time.Now()
+	rows, err := db.Query(
+		`SELECT r.id, r.attempts, r.offset_minutes, t.id, t.title, t.due_at, u.id, u.username, u.email
+		FROM task_reminders r
+		JOIN tasks t ON t.id = r.task_id
+		JOIN users u ON u.id = t.user_id
+		WHERE r.sent_at IS NULL AND r.remind_at <= ? AND (r.claimed_until IS NULL OR r.claimed_until <= ?)
+			AND r.attempts < ? AND t.completed = FALSE AND u.disabled_at IS NULL
+		ORDER BY r.remind_at LIMIT ?`,
+		now, now, maxReminderAttempts, reminderBatchSize,
+	)
+	if err != nil {
+		logger.Errorf("Failed to list due reminders: %v", err)
+		return
+	}
+
+	var due []queuedReminder
+	for rows.Next() {
+		var q queuedReminder
+		err := rows.Scan(
+			&q.id, &q.attempt, &q.n.OffsetMinutes, &q.n.TaskID, &q.n.Title, &q.n.DueAt,
+			&q.n.UserID, &q.n.Username, &q.n.Email,
+		)
+		if err != nil {
+			rows.Close()
+			logger.Errorf("Failed to scan due reminder: %v", err)
+			return
+		}
+		due = append(due, q)
+	}
+	rows.Close()
+
+	for _, q := range due {
+		// 先占用提醒并计入一次尝试，多实例部署时只有一个实例会发送
+		result, err := db.Exec(
+			`UPDATE task_reminders SET claimed_until = ?, attempts = attempts + 1
+			WHERE id = ? AND sent_at IS NULL AND attempts = ? AND (claimed_until IS NULL OR claimed_until <= ?)`,
+			now.Add(reminderClaimTTL), q.id, q.attempt, now,
+		)
+		if err != nil {
+			logger.Errorf("Failed to claim reminder %d: %v", q.id, err)
+			continue
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+		q.attempt++
+
+		select {
+		case reminderQueue <- q:
+		default:
+			// 通道已满，释放提醒留待下次发送，本次不计入尝试次数
+			logger.Warnf("Reminder queue full, deferring reminder %d", q.id)
+			if _, err := db.Exec("UPDATE task_reminders SET claimed_until = NULL, attempts = attempts - 1 WHERE id = ?", q.id); err != nil {
+				logger.Errorf("Failed to release reminder %d: %v", q.id, err)
+			}
+		}
+	}
+}
+
+// syncTaskReminders 按任务当前的截止时间和提醒偏移保存提醒；提醒时间变化的提醒会重新发送，
+// 保存时提醒时间已经过去的偏移直接视为已发送，避免设置截止时间后立即收到一批过期的提醒。在调用方的事务中执行
+func syncTaskReminders(tx *sql.Tx, task *Task) error {
+	if task.ReminderOffsets == nil {
+		task.ReminderOffsets = []int{}
+	}
+
+	args := []interface{}{task.ID}
+	for _, offset := range task.ReminderOffsets {
+		args = append(args, offset)
+	}
+	query := "DELETE FROM task_reminders WHERE task_id = ?"
+	if len(task.ReminderOffsets) > 0 {
+		query += " AND offset_minutes NOT IN (" + placeholders(len(task.ReminderOffsets)) + ")"
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, offset := range task.ReminderOffsets {
+		// 没有截止时间时只保存偏移，不会触发
+		var remindAt, sentAt *time.Time
+		if task.DueAt != nil {
+			t := task.DueAt.Add(-time.Duration(offset) * time.Minute)
+			remindAt = &t
+			if !t.After(now) {
+				sentAt = &now
+			}
+		}
+		// MySQL 按顺序计算赋值，发送状态需要在 remind_at 更新之前与旧值比较
+		_, err := tx.Exec(
+			`INSERT INTO task_reminders (task_id, offset_minutes, remind_at, sent_at) VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				sent_at = IF(remind_at <=> VALUES(remind_at), sent_at, VALUES(sent_at)),
+				attempts = IF(remind_at <=> VALUES(remind_at), attempts, 0),
+				claimed_until = IF(remind_at <=> VALUES(remind_at), claimed_until, NULL),
+				remind_at = VALUES(remind_at)`,
+			task.ID, offset, remindAt, sentAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadReminderOffsets 为一组任务填充提醒偏移
+func loadReminderOffsets(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	index := make(map[int]*Task, len(tasks))
+	args := make([]interface{}, len(tasks))
+	for i := range tasks {
+		tasks[i].ReminderOffsets = []int{}
+		index[tasks[i].ID] = &tasks[i]
+		args[i] = tasks[i].ID
+	}
+
+	rows, err := db.Query(
+		"SELECT task_id, offset_minutes FROM task_reminders WHERE task_id IN ("+placeholders(len(tasks))+") ORDER BY offset_minutes DESC",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, offset int
+		if err := rows.Scan(&taskID, &offset); err != nil {
+			return err
+		}
+		if task, ok := index[taskID]; ok {
+			task.ReminderOffsets = append(task.ReminderOffsets, offset)
+		}
+	}
+	return rows.Err()
+}
+
+// placeholders 生成 n 个以逗号分隔的 ? 占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/internal/scheduler"
+	"task-scheduler/pkg/logger"
+)
+
+// decodeRetentionPolicy 解析并校验保留策略请求体
+func decodeRetentionPolicy(w http.ResponseWriter, r *http.Request) (*model.RetentionPolicy, bool) {
+	var policy model.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		logger.Errorf("Error decoding retention policy: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	for _, v := range []*int{policy.MaxAgeDays, policy.KeepLast, policy.PurgeAfterDays} {
+		if v != nil && *v < 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "Retention values must not be negative")
+			return nil, false
+		}
+	}
+	return &policy, true
+}
+
+// checkRetentionTask 检查路由中的任务是否存在
+func checkRetentionTask(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	taskID, err := strconv.ParseUint(mux.Vars(r)["taskId"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid task ID")
+		return 0, false
+	}
+	if _, err := repository.NewTaskRepository(jobDB.WithContext(r.Context())).GetById(uint(taskID)); err != nil {
+		logger.Errorf("Error checking task existence: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Task not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check task existence")
+		}
+		return 0, false
+	}
+	return uint(taskID), true
+}
+
+// getRetentionPolicyHandler 获取全局保留策略
+func getRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	policy, err := repository.NewRetentionRepository(jobDB.WithContext(r.Context())).GetGlobal()
+	if err != nil {
+		logger.Errorf("Error getting retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get retention policy")
+		return
+	}
+	if policy == nil {
+		defaults := scheduler.DefaultRetentionPolicy
+		policy = &defaults
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// recordRetentionAudit 记录策略的保存，原先未配置时记为创建；全局策略的资源ID为 global，任务级策略为任务ID
+func recordRetentionAudit(r *http.Request, resourceID interface{}, before, after *model.RetentionPolicy) {
+	action := auditActionUpdate
+	if before == nil {
+		action = auditActionCreate
+	}
+	recordAudit(r, action, auditResourceRetentionPolicy, resourceID, before, after)
+}
+
+// updateRetentionPolicyHandler 更新全局保留策略，请求体中未给出的项保留原值（未配置过时为默认值）
+func updateRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	policy, ok := decodeRetentionPolicy(w, r)
+	if !ok {
+		return
+	}
+	policy.TaskID = nil
+
+	repo := repository.NewRetentionRepository(jobDB.WithContext(r.Context()))
+	before, err := repo.GetGlobal()
+	if err != nil {
+		logger.Errorf("Error getting retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save retention policy")
+		return
+	}
+	current := scheduler.DefaultRetentionPolicy.Merge(before)
+	merged := current.Merge(policy)
+	policy, err = repo.Save(&merged)
+	if err != nil {
+		logger.Errorf("Error saving retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save retention policy")
+		return
+	}
+	recordRetentionAudit(r, "global", before, policy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// getTaskRetentionPoliciesHandler 获取所有任务级保留策略
+func getTaskRetentionPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	policies, err := repository.NewRetentionRepository(jobDB.WithContext(r.Context())).ListTaskPolicies()
+	if err != nil {
+		logger.Errorf("Error getting task retention policies: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get retention policies")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policies)
+}
+
+// updateTaskRetentionPolicyHandler 设置任务级保留策略（未设置的字段沿用全局策略，0 表示不限）
+func updateTaskRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := checkRetentionTask(w, r)
+	if !ok {
+		return
+	}
+
+	policy, ok := decodeRetentionPolicy(w, r)
+	if !ok {
+		return
+	}
+	policy.TaskID = &taskID
+
+	repo := repository.NewRetentionRepository(jobDB.WithContext(r.Context()))
+	before, err := repo.GetForTask(taskID)
+	if err != nil {
+		logger.Errorf("Error getting task retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save retention policy")
+		return
+	}
+	policy, err = repo.Save(policy)
+	if err != nil {
+		logger.Errorf("Error saving task retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save retention policy")
+		return
+	}
+	recordRetentionAudit(r, taskID, before, policy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// deleteTaskRetentionPolicyHandler 删除任务级保留策略
+func deleteTaskRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.ParseUint(mux.Vars(r)["taskId"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	repo := repository.NewRetentionRepository(jobDB.WithContext(r.Context()))
+	before, err := repo.GetForTask(uint(taskID))
+	if err != nil {
+		logger.Errorf("Error getting task retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete retention policy")
+		return
+	}
+	if err := repo.DeleteForTask(uint(taskID)); err != nil {
+		logger.Errorf("Error deleting task retention policy: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete retention policy")
+		return
+	}
+	if before != nil {
+		recordAudit(r, auditActionDelete, auditResourceRetentionPolicy, taskID, before, nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pruneExecutionsHandler 立即执行一次清理并返回统计结果
+func pruneExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := jobScheduler.PruneExecutions()
+	if err != nil {
+		logger.Errorf("Error pruning executions: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to prune executions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
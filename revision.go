@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+)
+
+// RevisionInfo 修订的作者与说明，通过 context 传给仓库层的写操作
+type RevisionInfo struct {
+	AuthorID   uint
+	AuthorName string
+	Message    string
+}
+
+type revisionInfoKey struct{}
+
+// WithRevisionInfo 返回携带修订信息的 context，使用该 context 保存任务时生成的修订会记录作者
+func WithRevisionInfo(ctx context.Context, info RevisionInfo) context.Context {
+	return context.WithValue(ctx, revisionInfoKey{}, info)
+}
+
+// revisionInfoFrom 读取 context 中的修订信息，未设置时视为系统变更
+func revisionInfoFrom(ctx context.Context) RevisionInfo {
+	if ctx == nil {
+		return RevisionInfo{}
+	}
+	info, _ := ctx.Value(revisionInfoKey{}).(RevisionInfo)
+	return info
+}
+
+// registerRevisionCallbacks 在 gorm 上注册回调，任务定义每次变化都会在同一事务中追加修订
+func registerRevisionCallbacks(db *gorm.DB) error {
+	record := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			return
+		}
+		task, ok := tx.Statement.Model.(*model.Task)
+		if !ok || task.ID == 0 {
+			return
+		}
+		if err := recordRevision(tx.Session(&gorm.Session{NewDB: true}), task); err != nil {
+			tx.AddError(fmt.Errorf("failed to record task revision: %w", err))
+		}
+	}
+
+	if err := db.Callback().Create().After("gorm:create").Register("revisions:after_create", record); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("revisions:after_update", record)
+}
+
+// recordRevision 任务定义与最新修订不同时追加新修订，并更新任务的当前修订号
+func recordRevision(db *gorm.DB, task *model.Task) error {
+	repo := repository.NewRevisionRepository(db)
+	latest, err := repo.Latest(task.ID)
+	if err != nil {
+		return err
+	}
+	// 只有状态等运行信息变化时不产生修订
+	if latest != nil && latest.Matches(task) {
+		task.Revision = latest.Revision
+		return nil
+	}
+
+	next := 1
+	if latest != nil {
+		next = latest.Revision + 1
+	}
+	rev := model.NewTaskRevision(task, next)
+	info := revisionInfoFrom(db.Statement.Context)
+	if info.AuthorID != 0 {
+		rev.AuthorID = &info.AuthorID
+	}
+	rev.AuthorName = info.AuthorName
+	rev.Message = info.Message
+	if _, err := repo.Create(rev); err != nil {
+		return err
+	}
+
+	// 不带 Model 更新，避免再次触发任务相关的回调
+	if err := db.Table("tasks").Where("id = ?", task.ID).UpdateColumn("revision", next).Error; err != nil {
+		return err
+	}
+	task.Revision = next
+	return nil
+}
+
+// RollbackTask 将任务定义回滚到指定修订（作为一个新修订保存），运行中的任务会按回滚后的定义重新注册
+func (s *Scheduler) RollbackTask(ctx context.Context, taskID uint, revision int) (*model.Task, error) {
+	db := s.db.WithContext(ctx)
+	task, err := repository.NewTaskRepository(db).GetById(taskID)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := repository.NewRevisionRepository(db).Get(taskID, revision)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Matches(task) {
+		return task, nil
+	}
+
+	info := revisionInfoFrom(ctx)
+	info.Message = fmt.Sprintf("rollback to revision %d", revision)
+	rev.ApplyTo(task)
+	task, err = repository.NewTaskRepository(s.db.WithContext(WithRevisionInfo(ctx, info))).Update(task)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Status == model.TaskStatusRunning {
+		before := *task
+		event := model.EventTaskStarted
+		if task.IsEnabled {
+			err = s.StartTask(task)
+		} else {
+			event = model.EventTaskStopped
+			err = s.StopTaskByID(task.ID)
+		}
+		if err != nil {
+			return task, fmt.Errorf("failed to re-register task: %w", err)
+		}
+		if event == model.EventTaskStopped {
+			task.Status = model.TaskStatusStopped
+		}
+		s.recordAudit(event, &before, task)
+	}
+	return task, nil
+}
@@ -3,26 +3,181 @@ package main
 import (
 	"net/http"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
-func registerRoutes(r *mux.Router) {
+// newRouter 创建路由器，/api 下未注册的路径交给原有 API（legacy）处理；外层记录包括 404 在内的所有请求的指标
+func newRouter(legacy http.Handler) http.Handler {
+	r := mux.NewRouter()
+	api := registerRoutes(r)
+	// 原有 API 没有角色和命名空间校验，只在认证后对系统管理员开放（系统管理员在所有命名空间中都是管理员），
+	// 需在其余 /api 路由之后注册
+	api.PathPrefix("/").Handler(requirePermission(permManageJobs, legacy.ServeHTTP))
+	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	return metricsMiddleware(r)
+}
+
+// notFoundHandler 未匹配任何路由时返回 404 问题详情
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, http.StatusNotFound, statusCode(http.StatusNotFound), "No route matches "+r.URL.Path, nil)
+}
+
+// registerRoutes 注册所有路由，返回需要认证的 /api 子路由
+func registerRoutes(r *mux.Router) *mux.Router {
+	// 请求ID、链路追踪与请求指标的路由标签
+	r.Use(requestIDMiddleware)
+	r.Use(otelmux.Middleware("task-scheduler"))
+	r.Use(routeLabelMiddleware)
+
 	// 健康检查路由
 	r.HandleFunc("/health", healthCheckHandler).Methods("GET")
 
+	// Prometheus指标，标签中含任务ID，只对系统管理员开放（抓取时使用管理员的API密钥）
+	r.Handle("/metrics", authMiddleware(requirePermission(permManageJobs, promhttp.Handler().ServeHTTP))).Methods("GET")
+
+	// 无需认证的登录路由，需在 /api 子路由之前注册
+	r.HandleFunc("/api/auth/login", loginHandler).Methods("POST")
+	r.HandleFunc("/api/auth/refresh", refreshTokenHandler).Methods("POST")
+	r.HandleFunc("/api/auth/verify-email", verifyEmailHandler).Methods("POST")
+	r.HandleFunc("/api/auth/forgot-password", forgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/api/auth/reset-password", resetPasswordHandler).Methods("POST")
+	r.HandleFunc("/api/auth/oidc/login", oidcLoginHandler).Methods("GET")
+	r.HandleFunc("/api/auth/oidc/callback", oidcCallbackHandler).Methods("GET")
+
+	// 其余 /api 路由都需要认证
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(authMiddleware)
+
+	// 认证相关路由
+	authRouter := api.PathPrefix("/auth").Subrouter()
+	authRouter.HandleFunc("/logout", logoutHandler).Methods("POST")
+	authRouter.HandleFunc("/me", getCurrentUserHandler).Methods("GET")
+	authRouter.HandleFunc("/verify-email/resend", resendVerificationHandler).Methods("POST")
+
+	// 个人API密钥相关路由，只能通过登录令牌管理
+	apiKeyRouter := api.PathPrefix("/api-keys").Subrouter()
+	apiKeyRouter.Use(sessionOnlyMiddleware)
+	apiKeyRouter.HandleFunc("", createAPIKeyHandler).Methods("POST")
+	apiKeyRouter.HandleFunc("", getAPIKeysHandler).Methods("GET")
+	apiKeyRouter.HandleFunc("/{id:[0-9]+}", updateAPIKeyHandler).Methods("PUT")
+	apiKeyRouter.HandleFunc("/{id:[0-9]+}", revokeAPIKeyHandler).Methods("DELETE")
+
 	// 用户相关路由
-	userRouter := r.PathPrefix("/api/users").Subrouter()
-	userRouter.HandleFunc("", createUserHandler).Methods("POST")
-	userRouter.HandleFunc("", getAllUsersHandler).Methods("GET")
+	userRouter := api.PathPrefix("/users").Subrouter()
+	userRouter.HandleFunc("", requirePermission(permManageUsers, createUserHandler)).Methods("POST")
+	userRouter.HandleFunc("", requirePermission(permManageUsers, getAllUsersHandler)).Methods("GET")
 	userRouter.HandleFunc("/{id:[0-9]+}", getUserHandler).Methods("GET")
 	userRouter.HandleFunc("/{id:[0-9]+}", updateUserHandler).Methods("PUT")
-	userRouter.HandleFunc("/{id:[0-9]+}", deleteUserHandler).Methods("DELETE")
+	userRouter.HandleFunc("/{id:[0-9]+}", patchUserHandler).Methods("PATCH")
+	userRouter.HandleFunc("/{id:[0-9]+}", requirePermission(permManageUsers, deleteUserHandler)).Methods("DELETE")
+	userRouter.HandleFunc("/{id:[0-9]+}/disable", requirePermission(permManageUsers, disableUserHandler)).Methods("POST")
+	userRouter.HandleFunc("/{id:[0-9]+}/enable", requirePermission(permManageUsers, enableUserHandler)).Methods("POST")
+	userRouter.HandleFunc("/{id:[0-9]+}/unlock", requirePermission(permManageUsers, unlockUserHandler)).Methods("POST")
 
 	// 任务相关路由
-	taskRouter := r.PathPrefix("/api/tasks").Subrouter()
+	taskRouter := api.PathPrefix("/tasks").Subrouter()
 	taskRouter.HandleFunc("", createTaskHandler).Methods("POST")
 	taskRouter.HandleFunc("", getAllTasksHandler).Methods("GET")
+	taskRouter.HandleFunc("/overdue", getOverdueTasksHandler).Methods("GET")
+	taskRouter.HandleFunc("/due-today", getTasksDueTodayHandler).Methods("GET")
+	taskRouter.HandleFunc("/mine", getMyTasksHandler).Methods("GET")
 	taskRouter.HandleFunc("/{id:[0-9]+}", getTaskHandler).Methods("GET")
 	taskRouter.HandleFunc("/{id:[0-9]+}", updateTaskHandler).Methods("PUT")
+	taskRouter.HandleFunc("/{id:[0-9]+}", patchTaskHandler).Methods("PATCH")
 	taskRouter.HandleFunc("/{id:[0-9]+}", deleteTaskHandler).Methods("DELETE")
+	taskRouter.HandleFunc("/{id:[0-9]+}/subtasks", getSubtasksHandler).Methods("GET")
+	taskRouter.HandleFunc("/{id:[0-9]+}/move", moveTaskHandler).Methods("POST")
+	taskRouter.HandleFunc("/{id:[0-9]+}/history", getTaskStatusHistoryHandler).Methods("GET")
+	taskRouter.HandleFunc("/{id:[0-9]+}/comments", getTaskCommentsHandler).Methods("GET")
+	taskRouter.HandleFunc("/{id:[0-9]+}/comments", createTaskCommentHandler).Methods("POST")
+	taskRouter.HandleFunc("/{id:[0-9]+}/activity", getTaskActivityHandler).Methods("GET")
+	taskRouter.HandleFunc("/{id:[0-9]+}/attachments", getTaskAttachmentsHandler).Methods("GET")
+	taskRouter.HandleFunc("/{id:[0-9]+}/attachments", uploadTaskAttachmentHandler).Methods("POST")
+	commentRouter := api.PathPrefix("/comments").Subrouter()
+	commentRouter.HandleFunc("/{id:[0-9]+}", updateCommentHandler).Methods("PUT")
+	commentRouter.HandleFunc("/{id:[0-9]+}", deleteCommentHandler).Methods("DELETE")
+	attachmentRouter := api.PathPrefix("/attachments").Subrouter()
+	attachmentRouter.HandleFunc("/{id:[0-9]+}", getAttachmentHandler).Methods("GET")
+	attachmentRouter.HandleFunc("/{id:[0-9]+}/content", downloadAttachmentHandler).Methods("GET")
+	attachmentRouter.HandleFunc("/{id:[0-9]+}", deleteAttachmentHandler).Methods("DELETE")
 	taskRouter.HandleFunc("/user/{userId:[0-9]+}", getTasksByUserHandler).Methods("GET")
+
+	// 项目与标签相关路由
+	projectRouter := api.PathPrefix("/projects").Subrouter()
+	projectRouter.HandleFunc("", createProjectHandler).Methods("POST")
+	projectRouter.HandleFunc("", getProjectsHandler).Methods("GET")
+	projectRouter.HandleFunc("/{id:[0-9]+}", getProjectHandler).Methods("GET")
+	projectRouter.HandleFunc("/{id:[0-9]+}", updateProjectHandler).Methods("PUT")
+	projectRouter.HandleFunc("/{id:[0-9]+}", deleteProjectHandler).Methods("DELETE")
+	projectRouter.HandleFunc("/{id:[0-9]+}/workflow", getProjectWorkflowHandler).Methods("GET")
+	projectRouter.HandleFunc("/{id:[0-9]+}/workflow", updateProjectWorkflowHandler).Methods("PUT")
+	projectRouter.HandleFunc("/{id:[0-9]+}/board", getProjectBoardHandler).Methods("GET")
+	api.HandleFunc("/board", getInboxBoardHandler).Methods("GET")
+	tagRouter := api.PathPrefix("/tags").Subrouter()
+	tagRouter.HandleFunc("", createTagHandler).Methods("POST")
+	tagRouter.HandleFunc("", getTagsHandler).Methods("GET")
+	tagRouter.HandleFunc("/{id:[0-9]+}", updateTagHandler).Methods("PUT")
+	tagRouter.HandleFunc("/{id:[0-9]+}", deleteTagHandler).Methods("DELETE")
+
+	// webhook相关路由
+	webhookRouter := api.PathPrefix("/webhooks").Subrouter()
+	webhookRouter.HandleFunc("", requirePermission(permManageJobs, createWebhookHandler)).Methods("POST")
+	webhookRouter.HandleFunc("", requirePermission(permManageJobs, getAllWebhooksHandler)).Methods("GET")
+	webhookRouter.HandleFunc("/{id:[0-9]+}", requirePermission(permManageJobs, getWebhookHandler)).Methods("GET")
+	webhookRouter.HandleFunc("/{id:[0-9]+}", requirePermission(permManageJobs, updateWebhookHandler)).Methods("PUT")
+	webhookRouter.HandleFunc("/{id:[0-9]+}", requirePermission(permManageJobs, deleteWebhookHandler)).Methods("DELETE")
+	webhookRouter.HandleFunc("/{id:[0-9]+}/deliveries", requirePermission(permManageJobs, getWebhookDeliveriesHandler)).Methods("GET")
+	webhookRouter.HandleFunc("/deliveries/{id:[0-9]+}/replay", requirePermission(permManageJobs, replayWebhookDeliveryHandler)).Methods("POST")
+
+	// 执行记录保留策略相关路由
+	retentionRouter := api.PathPrefix("/retention").Subrouter()
+	retentionRouter.HandleFunc("", requirePermission(permReadExecutions, getRetentionPolicyHandler)).Methods("GET")
+	retentionRouter.HandleFunc("", requirePermission(permManageJobs, updateRetentionPolicyHandler)).Methods("PUT")
+	retentionRouter.HandleFunc("/tasks", requirePermission(permReadExecutions, getTaskRetentionPoliciesHandler)).Methods("GET")
+	retentionRouter.HandleFunc("/tasks/{taskId:[0-9]+}", requirePermission(permManageJobs, updateTaskRetentionPolicyHandler)).Methods("PUT")
+	retentionRouter.HandleFunc("/tasks/{taskId:[0-9]+}", requirePermission(permManageJobs, deleteTaskRetentionPolicyHandler)).Methods("DELETE")
+	retentionRouter.HandleFunc("/prune", requirePermission(permOperateJobs, pruneExecutionsHandler)).Methods("POST")
+
+	// 命名空间相关路由
+	namespaceRouter := api.PathPrefix("/namespaces").Subrouter()
+	namespaceRouter.HandleFunc("", requirePermission(permManageJobs, createNamespaceHandler)).Methods("POST")
+	namespaceRouter.HandleFunc("", getNamespacesHandler).Methods("GET")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}", getNamespaceHandler).Methods("GET")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}", updateNamespaceHandler).Methods("PUT")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}", deleteNamespaceHandler).Methods("DELETE")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/members", getNamespaceMembersHandler).Methods("GET")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/members/{userId:[0-9]+}", updateNamespaceMemberHandler).Methods("PUT")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/members/{userId:[0-9]+}", removeNamespaceMemberHandler).Methods("DELETE")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/secrets", getSecretsHandler).Methods("GET")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/secrets", createSecretHandler).Methods("POST")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/secrets/{secretId:[0-9]+}", updateSecretHandler).Methods("PUT")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/secrets/{secretId:[0-9]+}", deleteSecretHandler).Methods("DELETE")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/calendars", getCalendarsHandler).Methods("GET")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/calendars", createCalendarHandler).Methods("POST")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/calendars/{calendarId:[0-9]+}", getCalendarHandler).Methods("GET")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/calendars/{calendarId:[0-9]+}", updateCalendarHandler).Methods("PUT")
+	namespaceRouter.HandleFunc("/{id:[0-9]+}/calendars/{calendarId:[0-9]+}", deleteCalendarHandler).Methods("DELETE")
+
+	// 定时任务操作相关路由，权限按任务所属命名空间校验
+	api.HandleFunc("/jobs", getJobsHandler).Methods("GET")
+	jobRouter := api.PathPrefix("/jobs/{taskId:[0-9]+}").Subrouter()
+	jobRouter.HandleFunc("/start", startJobHandler).Methods("POST")
+	jobRouter.HandleFunc("/stop", stopJobHandler).Methods("POST")
+	jobRouter.HandleFunc("/run", runJobHandler).Methods("POST")
+	jobRouter.HandleFunc("/executions", getJobExecutionsHandler).Methods("GET")
+	jobRouter.HandleFunc("/revisions", getJobRevisionsHandler).Methods("GET")
+	jobRouter.HandleFunc("/revisions/diff", diffJobRevisionsHandler).Methods("GET")
+	jobRouter.HandleFunc("/revisions/{revision:[0-9]+}", getJobRevisionHandler).Methods("GET")
+	jobRouter.HandleFunc("/revisions/{revision:[0-9]+}/rollback", rollbackJobHandler).Methods("POST")
+
+	// 审计日志相关路由
+	auditRouter := api.PathPrefix("/audit").Subrouter()
+	auditRouter.HandleFunc("", requirePermission(permReadAudit, getAuditLogsHandler)).Methods("GET")
+	auditRouter.HandleFunc("/export", requirePermission(permReadAudit, exportAuditLogsHandler)).Methods("GET")
+
+	// 任务统计相关路由
+	api.HandleFunc("/stats/tasks/{taskId:[0-9]+}", getTaskStatsHandler).Methods("GET")
+
+	return api
 }
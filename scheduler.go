@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 
 	"task-scheduler/internal/model"
@@ -20,10 +22,30 @@ type Scheduler struct {
 	db          *gorm.DB
 	taskRepo    *repository.TaskRepository
 	execRepo    *repository.ExecutionRepository
+	policyRepo  *repository.RetentionRepository
+	statsRepo   *repository.StatsRepository
 	jobs        map[uint]gocron.JobID // 任务ID到JobID的映射
+	webhooks    *WebhookDispatcher
+	audit       AuditFunc
 	mu          sync.RWMutex
 }
 
+// AuditFunc 记录由调度器自身发起的任务启停（启动时恢复任务、回滚后重新注册），
+// event 为 EventTaskStarted 或 EventTaskStopped，before/after 为变更前后的任务
+type AuditFunc func(event model.EventType, before, after *model.Task)
+
+// SetAuditFunc 设置调度器自身发起的启停的审计记录函数，需在 LoadAndStartTasks 之前调用
+func (s *Scheduler) SetAuditFunc(fn AuditFunc) {
+	s.audit = fn
+}
+
+// recordAudit 调用审计记录函数，未设置时忽略
+func (s *Scheduler) recordAudit(event model.EventType, before, after *model.Task) {
+	if s.audit != nil {
+		s.audit(event, before, after)
+	}
+}
+
 // NewScheduler 创建新的调度器
 func NewScheduler(db *gorm.DB) *Scheduler {
 	// 创建gocron调度器
@@ -35,13 +57,71 @@ func NewScheduler(db *gorm.DB) *Scheduler {
 	// 启动调度器
 	gc.Start()
 	
-	return &Scheduler{
+	// 任务定义的每次变更都记录修订，需在事件回调之前注册，使事件中带上新的修订号
+	if err := registerRevisionCallbacks(db); err != nil {
+		logger.Fatalf("Failed to register revision callbacks: %v", err)
+	}
+	
+	// 创建webhook投递器，并让仓库层的写操作产生事件
+	webhooks := NewWebhookDispatcher(db)
+	if err := registerEventCallbacks(db, webhooks); err != nil {
+		logger.Fatalf("Failed to register event callbacks: %v", err)
+	}
+	
+	// 定期重试失败的webhook投递
+	if _, err := gc.NewJob(
+		gocron.DurationJob(webhookRetryInterval),
+		gocron.NewTask(webhooks.RetryDue),
+	); err != nil {
+		logger.Fatalf("Failed to create webhook retry job: %v", err)
+	}
+	
+	s := &Scheduler{
 		gc:          gc,
 		db:          db,
 		taskRepo:    repository.NewTaskRepository(db),
 		execRepo:    repository.NewExecutionRepository(db),
+		policyRepo:  repository.NewRetentionRepository(db),
+		statsRepo:   repository.NewStatsRepository(db),
 		jobs:        make(map[uint]gocron.JobID),
+		webhooks:    webhooks,
 	}
+	
+	// 定期按保留策略清理执行记录
+	if _, err := gc.NewJob(
+		gocron.DurationJob(retentionInterval),
+		gocron.NewTask(s.runRetention),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	); err != nil {
+		logger.Fatalf("Failed to create retention job: %v", err)
+	}
+	
+	// 定期检查任务SLA
+	sla := newSLAMonitor(s)
+	if _, err := gc.NewJob(
+		gocron.DurationJob(slaCheckInterval),
+		gocron.NewTask(sla.check),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	); err != nil {
+		logger.Fatalf("Failed to create SLA check job: %v", err)
+	}
+	
+	return s
+}
+
+// Webhooks 返回调度器的webhook投递器
+func (s *Scheduler) Webhooks() *WebhookDispatcher {
+	return s.webhooks
+}
+
+// RegisterMaintenanceJob 注册按固定间隔执行的维护作业，上一次未结束时跳过本次
+func (s *Scheduler) RegisterMaintenanceJob(interval time.Duration, fn func()) error {
+	_, err := s.gc.NewJob(
+		gocron.DurationJob(interval),
+		gocron.NewTask(fn),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	return err
 }
 
 // LoadAndStartTasks 从数据库加载并启动所有启用的任务
@@ -52,10 +132,12 @@ func (s *Scheduler) LoadAndStartTasks() error {
 	}
 	
 	for _, task := range tasks {
+		before := task
 		if err := s.StartTask(&task); err != nil {
 			logger.Warnf("Failed to start task %d: %v", task.ID, err)
 		} else {
 			logger.Infof("Started task: %s (ID: %d)", task.Name, task.ID)
+			s.recordAudit(model.EventTaskStarted, &before, &task)
 		}
 	}
 	
@@ -73,10 +155,8 @@ func (s *Scheduler) StartTask(task *model.Task) error {
 	job, err := s.gc.NewJob(
 		gocron.CronJob(task.CronExpr, false),
 		gocron.NewTask(
-			ExecuteTask,
-			s.db,
+			s.runScheduled,
 			task.ID,
-			s.execRepo,
 		),
 	)
 	if err != nil {
@@ -90,9 +170,12 @@ func (s *Scheduler) StartTask(task *model.Task) error {
 	
 	// 更新任务状态
 	task.Status = model.TaskStatusRunning
-	_, err = s.taskRepo.Update(task)
+	if _, err = s.taskRepo.Update(task); err != nil {
+		return err
+	}
 	
-	return err
+	s.webhooks.Publish(model.EventTaskStarted, task)
+	return nil
 }
 
 // StopTaskByID 停止指定ID的任务
@@ -118,54 +201,44 @@ func (s *Scheduler) StopTaskByID(taskID uint) error {
 	}
 	
 	task.Status = model.TaskStatusStopped
-	_, err = s.taskRepo.Update(task)
+	if _, err = s.taskRepo.Update(task); err != nil {
+		return err
+	}
 	
-	return err
+	if exists {
+		s.webhooks.Publish(model.EventTaskStopped, task)
+	}
+	return nil
 }
 
 // ExecuteTaskNow 立即执行任务（不影响定时调度）
 func (s *Scheduler) ExecuteTaskNow(taskID uint) (uint, error) {
-	task, err := s.taskRepo.GetById(taskID)
+	return s.ExecuteTaskNowContext(context.Background(), taskID)
+}
+
+// ExecuteTaskNowContext 立即执行任务，执行链路挂在 ctx 中的调用方链路之下
+func (s *Scheduler) ExecuteTaskNowContext(ctx context.Context, taskID uint) (uint, error) {
+	queuedAt := time.Now()
+	ctx, span := tracer.Start(ctx, "scheduler.ExecuteTaskNow",
+		trace.WithAttributes(attribute.Int64("task.id", int64(taskID))),
+	)
+	defer span.End()
+	
+	task, err := repository.NewTaskRepository(s.db.WithContext(ctx)).GetById(taskID)
 	if err != nil {
+		span.RecordError(err)
 		return 0, fmt.Errorf("failed to get task: %w", err)
 	}
 	
 	// 创建执行记录
-	execution := &model.TaskExecution{
-		TaskID:    taskID,
-		StartTime: time.Now(),
-		Status:    model.ExecutionStatusRunning,
-	}
-	
-	execution, err = s.execRepo.Create(execution)
+	execution, err := s.createExecution(ctx, task)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create execution record: %w", err)
 	}
 	
-	// 异步执行任务
-	go func() {
-		ctx := context.Background()
-		output, err := executeCommand(ctx, task.Command)
-		
-		endTime := time.Now()
-		status := model.ExecutionStatusSuccess
-		errorMsg := ""
-		
-		if err != nil {
-			status = model.ExecutionStatusFailed
-			errorMsg = err.Error()
-		}
-		
-		// 更新执行记录
-		execution.EndTime = &endTime
-		execution.Status = status
-		execution.Output = output
-		execution.Error = errorMsg
-		
-		if _, err := s.execRepo.Update(execution); err != nil {
-			logger.Errorf("Failed to update execution record %d: %v", execution.ID, err)
-		}
-	}()
+	// 异步执行任务，沿用调用方的链路但不受其取消影响
+	runCtx := trace.ContextWithSpanContext(context.Background(), span.SpanContext())
+	go s.runExecution(runCtx, task, execution, queuedAt)
 	
 	return execution.ID, nil
 }
@@ -175,4 +248,7 @@ func (s *Scheduler) Stop() {
 	if s.gc != nil {
 		s.gc.Shutdown()
 	}
+	if s.webhooks != nil {
+		s.webhooks.Stop()
+	}
 }
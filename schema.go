@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// db 用户、待办和账号等原生 SQL 仓库使用的连接池，与调度器共用 GORM 的底层连接
+var db *sql.DB
+
+// initSQLDB 设置原生 SQL 连接池，创建缺失的表和列，没有用户时创建初始管理员
+func initSQLDB(ctx context.Context, conn *sql.DB) error {
+	db = conn
+	if err := migrateSchema(); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return seedAdmin(ctx)
+}
+
+// schemaStatements 待办应用新增表的建表语句，按顺序执行且可重复执行
+var schemaStatements = []string{
+	// 刷新令牌（只保存哈希），同一 family 内轮换
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		family_id VARCHAR(64) NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_refresh_tokens_family (family_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 注销后在过期前拒绝的访问令牌
+	`CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+		jti VARCHAR(64) PRIMARY KEY,
+		expires_at DATETIME NOT NULL
+	)`,
+	// 个人 API 密钥（只保存哈希）
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(16) NOT NULL UNIQUE,
+		key_hash CHAR(64) NOT NULL,
+		scopes VARCHAR(200) NOT NULL,
+		allowed_ips VARCHAR(1000) NOT NULL DEFAULT '',
+		expires_at DATETIME NULL,
+		last_used_at DATETIME NULL,
+		revoked_at DATETIME NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_api_keys_user (user_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 审计日志，只追加不修改；不设外键，删除用户后记录仍保留
+	`CREATE TABLE IF NOT EXISTS audit_logs (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		actor_id INT NULL,
+		actor_name VARCHAR(100) NOT NULL DEFAULT '',
+		action VARCHAR(50) NOT NULL,
+		resource_type VARCHAR(50) NOT NULL,
+		resource_id VARCHAR(64) NOT NULL,
+		before_data JSON NULL,
+		after_data JSON NULL,
+		changes JSON NULL,
+		request_id VARCHAR(64) NOT NULL DEFAULT '',
+		source_ip VARCHAR(45) NOT NULL DEFAULT '',
+		created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+		INDEX idx_audit_logs_created (created_at),
+		INDEX idx_audit_logs_actor (actor_id, created_at),
+		INDEX idx_audit_logs_resource (resource_type, resource_id, created_at)
+	)`,
+	// 邮箱验证和密码重置令牌（只保存哈希），一次性使用
+	`CREATE TABLE IF NOT EXISTS user_tokens (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		purpose VARCHAR(20) NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_tokens_user (user_id, purpose),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 按账号和来源 IP 累计的登录失败次数，某个来源被锁定不影响账号从其他地址登录
+	`CREATE TABLE IF NOT EXISTS login_failures (
+		user_id INT NOT NULL,
+		source_ip VARCHAR(45) NOT NULL,
+		failed_count INT NOT NULL DEFAULT 0,
+		locked_until DATETIME NULL,
+		last_failed_at DATETIME NULL,
+		last_success_at DATETIME NULL,
+		PRIMARY KEY (user_id, source_ip),
+		INDEX idx_login_failures_failed (last_failed_at),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 待办任务的提醒，remind_at 为空表示任务没有截止时间
+	`CREATE TABLE IF NOT EXISTS task_reminders (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		task_id INT NOT NULL,
+		offset_minutes INT NOT NULL,
+		remind_at DATETIME NULL,
+		sent_at DATETIME NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		claimed_until DATETIME NULL,
+		UNIQUE KEY uk_task_reminders_offset (task_id, offset_minutes),
+		INDEX idx_task_reminders_due (sent_at, remind_at),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	)`,
+	// 重复待办任务的系列，保存生成下一个实例所用的模板
+	`CREATE TABLE IF NOT EXISTS task_series (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		recurrence VARCHAR(100) NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		priority VARCHAR(10) NOT NULL DEFAULT 'normal',
+		reminder_offsets VARCHAR(100) NOT NULL DEFAULT '',
+		ended_at DATETIME NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 待办任务的项目，同一用户下名称唯一
+	`CREATE TABLE IF NOT EXISTS projects (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		description TEXT,
+		color VARCHAR(7) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		UNIQUE KEY uk_projects_name (user_id, name),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 待办任务的标签及其与任务的多对多关联
+	`CREATE TABLE IF NOT EXISTS tags (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		color VARCHAR(7) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY uk_tags_name (user_id, name),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS task_tags (
+		task_id INT NOT NULL,
+		tag_id INT NOT NULL,
+		PRIMARY KEY (task_id, tag_id),
+		INDEX idx_task_tags_tag (tag_id),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	)`,
+	// 任务上的检查项，按 position 排序
+	`CREATE TABLE IF NOT EXISTS task_checklist_items (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		task_id INT NOT NULL,
+		text VARCHAR(500) NOT NULL,
+		done BOOLEAN NOT NULL DEFAULT FALSE,
+		position INT NOT NULL DEFAULT 0,
+		INDEX idx_task_checklist_items_task (task_id, position),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	)`,
+	// 项目自定义的工作流状态与允许的转换，没有记录的项目使用默认工作流
+	`CREATE TABLE IF NOT EXISTS project_statuses (
+		project_id INT NOT NULL,
+		status VARCHAR(50) NOT NULL,
+		category VARCHAR(20) NOT NULL,
+		position INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (project_id, status),
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS project_status_transitions (
+		project_id INT NOT NULL,
+		from_status VARCHAR(50) NOT NULL,
+		to_status VARCHAR(50) NOT NULL,
+		PRIMARY KEY (project_id, from_status, to_status),
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	)`,
+	// 任务的状态变更历史，changed_at 为进入 to_status 的时间
+	`CREATE TABLE IF NOT EXISTS task_status_history (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		task_id INT NOT NULL,
+		from_status VARCHAR(50) NOT NULL DEFAULT '',
+		to_status VARCHAR(50) NOT NULL,
+		to_category VARCHAR(20) NOT NULL,
+		actor_id INT NULL,
+		changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_task_status_history_task (task_id, changed_at),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	)`,
+	// 任务评论，parent_id 指向被回复的评论；有回复的评论删除时只清空正文
+	`CREATE TABLE IF NOT EXISTS task_comments (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		task_id INT NOT NULL,
+		parent_id INT NULL,
+		author_id INT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		edited_at DATETIME NULL,
+		deleted_at DATETIME NULL,
+		INDEX idx_task_comments_task (task_id, created_at),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY (parent_id) REFERENCES task_comments(id) ON DELETE CASCADE,
+		FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS comment_mentions (
+		comment_id INT NOT NULL,
+		user_id INT NOT NULL,
+		PRIMARY KEY (comment_id, user_id),
+		FOREIGN KEY (comment_id) REFERENCES task_comments(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 任务附件，内容保存在对象存储中；任务删除后 task_id 置空，由后台任务清理内容
+	`CREATE TABLE IF NOT EXISTS task_attachments (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		task_id INT NULL,
+		uploader_id INT NULL,
+		filename VARCHAR(255) NOT NULL,
+		content_type VARCHAR(100) NOT NULL,
+		size BIGINT NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		storage_key VARCHAR(255) NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		cleanup_attempted_at DATETIME NULL,
+		UNIQUE KEY uniq_task_attachments_storage_key (storage_key),
+		INDEX idx_task_attachments_task (task_id, created_at),
+		INDEX idx_task_attachments_cleanup (task_id, cleanup_attempted_at),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE SET NULL,
+		FOREIGN KEY (uploader_id) REFERENCES users(id) ON DELETE SET NULL
+	)`,
+	// 任务的负责人与关注者
+	`CREATE TABLE IF NOT EXISTS task_assignees (
+		task_id INT NOT NULL,
+		user_id INT NOT NULL,
+		PRIMARY KEY (task_id, user_id),
+		INDEX idx_task_assignees_user (user_id),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS task_watchers (
+		task_id INT NOT NULL,
+		user_id INT NOT NULL,
+		PRIMARY KEY (task_id, user_id),
+		INDEX idx_task_watchers_user (user_id),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 外部身份提供方（OIDC）账号与本地用户的绑定
+	`CREATE TABLE IF NOT EXISTS user_identities (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		issuer VARCHAR(255) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_login_at DATETIME NULL,
+		UNIQUE KEY uk_user_identities_subject (issuer, subject),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 进行中的 OIDC 登录（state 只保存哈希），回调时一次性取出
+	`CREATE TABLE IF NOT EXISTS oidc_login_states (
+		state_hash CHAR(64) PRIMARY KEY,
+		code_verifier VARCHAR(128) NOT NULL,
+		nonce VARCHAR(64) NOT NULL,
+		expires_at DATETIME NOT NULL
+	)`,
+}
+
+// schemaColumn 需要补充到已有表上的列
+type schemaColumn struct {
+	Table      string
+	Column     string
+	Definition string
+	Backfill   string // 新增列后执行一次的数据回填语句，可为空
+}
+
+// schemaColumns 已有表上新增的列，缺失时才会添加
+var schemaColumns = []schemaColumn{
+	// 升级时最早创建的用户成为管理员，其余用户默认只读
+	{"users", "role", "VARCHAR(20) NOT NULL DEFAULT 'viewer'", "UPDATE users SET role = 'admin' ORDER BY id LIMIT 1"},
+	// 已有用户视为邮箱已验证
+	{"users", "email_verified_at", "DATETIME NULL", "UPDATE users SET email_verified_at = created_at"},
+	{"users", "disabled_at", "DATETIME NULL", ""},
+	// 乐观并发控制的版本号，每次更新加一
+	{"users", "version", "INT NOT NULL DEFAULT 1", ""},
+	{"tasks", "version", "INT NOT NULL DEFAULT 1", ""},
+	{"tasks", "due_at", "DATETIME NULL", ""},
+	{"tasks", "priority", "VARCHAR(10) NOT NULL DEFAULT 'normal'", ""},
+	{"tasks", "series_id", "INT NULL", "CREATE INDEX idx_tasks_series ON tasks (series_id, due_at)"},
+	{"tasks", "project_id", "INT NULL",
+		"ALTER TABLE tasks ADD CONSTRAINT fk_tasks_project FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE SET NULL"},
+	// 删除任务时一并删除其子任务
+	{"tasks", "parent_id", "INT NULL",
+		"ALTER TABLE tasks ADD CONSTRAINT fk_tasks_parent FOREIGN KEY (parent_id) REFERENCES tasks(id) ON DELETE CASCADE"},
+	{"tasks", "require_children_complete", "BOOLEAN NOT NULL DEFAULT FALSE", ""},
+	// 已完成的任务进入 done 状态；看板排序按创建顺序初始化
+	{"tasks", "status", "VARCHAR(50) NOT NULL DEFAULT 'todo'", "UPDATE tasks SET status = 'done' WHERE completed = TRUE"},
+	{"tasks", "board_rank", "DOUBLE NOT NULL DEFAULT 0", "UPDATE tasks SET board_rank = id * 1024"},
+	{"tasks", "status_changed_at", "DATETIME NULL", "UPDATE tasks SET status_changed_at = updated_at"},
+	// 已有任务的创建者视为其所有者；创建者账号删除后置空
+	{"tasks", "created_by", "INT NULL", "UPDATE tasks SET created_by = user_id"},
+}
+
+// schemaConstraint 需要补充到已有表上的约束
+type schemaConstraint struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+// schemaConstraints 已有表上新增的约束，在新增的列回填之后添加，缺失时才会添加
+var schemaConstraints = []schemaConstraint{
+	{"tasks", "fk_tasks_created_by", "FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL"},
+}
+
+// migrateSchema 创建缺失的表、列和约束
+func migrateSchema() error {
+	for _, stmt := range schemaStatements {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	for _, col := range schemaColumns {
+		var count int
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+			col.Table, col.Column,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.Table, col.Column, col.Definition)); err != nil {
+			return err
+		}
+		if col.Backfill != "" {
+			if _, err := db.Exec(col.Backfill); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range schemaConstraints {
+		var count int
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = ?",
+			c.Table, c.Name,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", c.Table, c.Name, c.Definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
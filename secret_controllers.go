@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/pkg/logger"
+)
+
+// secretRequest 创建或修改密钥的请求体，value 只能写入，不会在响应中返回
+type secretRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// loadSecret 获取路由中命名空间下的密钥，并校验当前用户在该命名空间拥有指定权限
+func loadSecret(w http.ResponseWriter, r *http.Request, perm Permission) (*model.Secret, bool) {
+	ns, ok := loadNamespace(w, r, perm)
+	if !ok {
+		return nil, false
+	}
+	id, ok := parseNamespaceResourceID(w, r, "secretId", "Invalid secret ID")
+	if !ok {
+		return nil, false
+	}
+
+	secret, err := repository.NewSecretRepository(jobDB.WithContext(r.Context())).Get(ns.ID, id)
+	if err != nil {
+		logger.Errorf("Error getting secret: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Secret not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get secret")
+		}
+		return nil, false
+	}
+	return secret, true
+}
+
+// getSecretsHandler 获取命名空间中的密钥名称
+func getSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	secrets, err := repository.NewSecretRepository(jobDB.WithContext(r.Context())).List(ns.ID)
+	if err != nil {
+		logger.Errorf("Error getting secrets: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get secrets")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(secrets)
+}
+
+// createSecretHandler 在命名空间中创建密钥
+func createSecretHandler(w http.ResponseWriter, r *http.Request) {
+	ns, ok := loadNamespace(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	var req secretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding secret: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	secret := &model.Secret{NamespaceID: ns.ID, Name: req.Name, Value: req.Value}
+	if err := secret.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	secret, err := repository.NewSecretRepository(jobDB.WithContext(r.Context())).Create(secret)
+	if err != nil {
+		logger.Errorf("Error creating secret: %v", err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Secret name already exists in this namespace")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to create secret")
+		}
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceSecret, secret.ID, nil, secret)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(secret)
+}
+
+// updateSecretHandler 修改密钥名称和值
+func updateSecretHandler(w http.ResponseWriter, r *http.Request) {
+	secret, ok := loadSecret(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	var req secretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding secret update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	before := *secret
+	secret.Name = req.Name
+	secret.Value = req.Value
+	if err := secret.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	secret, err := repository.NewSecretRepository(jobDB.WithContext(r.Context())).Update(secret)
+	if err != nil {
+		logger.Errorf("Error updating secret: %v", err)
+		if isDuplicate(err) {
+			sendErrorResponse(w, http.StatusConflict, "Secret name already exists in this namespace")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to update secret")
+		}
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceSecret, secret.ID, &before, secret)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(secret)
+}
+
+// deleteSecretHandler 删除密钥
+func deleteSecretHandler(w http.ResponseWriter, r *http.Request) {
+	secret, ok := loadSecret(w, r, permManageJobs)
+	if !ok {
+		return
+	}
+
+	if err := repository.NewSecretRepository(jobDB.WithContext(r.Context())).Delete(secret.NamespaceID, secret.ID); err != nil {
+		logger.Errorf("Error deleting secret: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete secret")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceSecret, secret.ID, secret, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"task-scheduler/pkg/logger"
+)
+
+// 修改系列中的实例时的作用范围
+const (
+	seriesScopeThis   = "this"   // 只修改当前实例
+	seriesScopeFuture = "future" // 修改当前及之后的实例和系列模板
+)
+
+const recurrenceCheckInterval = time.Minute
+
+// parseSeriesScope 解析 scope 参数，默认只作用于当前实例
+func parseSeriesScope(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch scope := r.URL.Query().Get("scope"); scope {
+	case "", seriesScopeThis:
+		return seriesScopeThis, true
+	case seriesScopeFuture:
+		return scope, true
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "scope must be this or future")
+		return "", false
+	}
+}
+
+// nextOccurrence 返回重复规则在 after 之后的下一次触发时间
+func nextOccurrence(recurrence string, after time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(recurrence)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
+
+// joinOffsets 将提醒偏移保存为逗号分隔的字符串
+func joinOffsets(offsets []int) string {
+	parts := make([]string, len(offsets))
+	for i, offset := range offsets {
+		parts[i] = strconv.Itoa(offset)
+	}
+	return strings.Join(parts, ",")
+}
+
+// splitOffsets 解析 joinOffsets 保存的提醒偏移
+func splitOffsets(s string) []int {
+	offsets := []int{}
+	for _, part := range splitList(s) {
+		if offset, err := strconv.Atoi(part); err == nil {
+			offsets = append(offsets, offset)
+		}
+	}
+	return offsets
+}
+
+// createSeries 以任务为模板创建重复系列，任务没有截止时间时取下一次触发时间；
+// 在调用方的事务中插入，与任务的写入一起提交
+func createSeries(tx *sql.Tx, task *Task) error {
+	if task.DueAt == nil {
+		due, err := nextOccurrence(task.Recurrence, time.Now())
+		if err != nil {
+			return err
+		}
+		task.DueAt = &due
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO task_series (user_id, recurrence, title, description, priority, reminder_offsets) VALUES (?, ?, ?, ?, ?, ?)",
+		task.UserID, task.Recurrence, task.Title, task.Description, task.Priority, joinOffsets(task.ReminderOffsets),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	seriesID := int(id)
+	task.SeriesID = &seriesID
+	return nil
+}
+
+// updateSeriesFrom 将实例的修改应用到系列模板，以及该实例之后尚未完成的实例，在一个事务中完成
+func updateSeriesFrom(task *Task) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE task_series SET recurrence = ?, title = ?, description = ?, priority = ?, reminder_offsets = ?, ended_at = NULL
+		WHERE id = ?`,
+		task.Recurrence, task.Title, task.Description, task.Priority, joinOffsets(task.ReminderOffsets), *task.SeriesID,
+	)
+	if err != nil {
+		return err
+	}
+	if task.DueAt == nil {
+		return tx.Commit()
+	}
+
+	rows, err := tx.Query(
+		"SELECT id FROM tasks WHERE series_id = ? AND id <> ? AND completed = FALSE AND due_at > ?",
+		*task.SeriesID, task.ID, *task.DueAt,
+	)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := tx.Exec(
+			"UPDATE tasks SET title = ?, description = ?, priority = ?, version = version + 1 WHERE id = ?",
+			task.Title, task.Description, task.Priority, id,
+		); err != nil {
+			return err
+		}
+		instance := Task{ID: id, ReminderOffsets: task.ReminderOffsets}
+		if err := tx.QueryRow("SELECT due_at FROM tasks WHERE id = ?", id).Scan(&instance.DueAt); err != nil {
+			return err
+		}
+		if err := syncTaskReminders(tx, &instance); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// endSeries 结束系列，之后不再生成新实例
+func endSeries(seriesID int) error {
+	_, err := db.Exec("UPDATE task_series SET ended_at = ? WHERE id = ? AND ended_at IS NULL", time.Now(), seriesID)
+	return err
+}
+
+// applySeriesChanges 在任务更新后处理重复规则：普通任务设置规则后成为系列的第一个实例；
+// scope=future 时同步系列模板或结束系列；实例完成后生成下一个实例
+func applySeriesChanges(existing, updated *Task, scope string) error {
+	switch {
+	case existing.SeriesID == nil && updated.Recurrence != "":
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := createSeries(tx, updated); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE tasks SET series_id = ?, due_at = ?, version = version + 1 WHERE id = ?",
+			*updated.SeriesID, updated.DueAt, updated.ID,
+		); err != nil {
+			return err
+		}
+		if err := syncTaskReminders(tx, updated); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	case existing.SeriesID != nil && scope == seriesScopeFuture:
+		if updated.Recurrence == "" {
+			if err := endSeries(*existing.SeriesID); err != nil {
+				return err
+			}
+		} else if err := updateSeriesFrom(updated); err != nil {
+			return err
+		}
+	}
+
+	if updated.SeriesID != nil && updated.Completed && !existing.Completed {
+		if _, err := advanceSeries(*updated.SeriesID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSeriesFrom 结束系列，并删除该实例及之后尚未完成的实例
+func deleteSeriesFrom(ctx context.Context, task *Task) error {
+	if err := endSeries(*task.SeriesID); err != nil {
+		return err
+	}
+	if task.DueAt == nil {
+		return DeleteTask(ctx, task.ID)
+	}
+	_, err := db.Exec(
+		"DELETE FROM tasks WHERE series_id = ? AND (id = ? OR (completed = FALSE AND due_at > ?))",
+		*task.SeriesID, task.ID, *task.DueAt,
+	)
+	return err
+}
+
+// advanceSeries 最新的实例已完成或已过截止时间（规则已触发）时生成下一个实例，否则返回 nil。
+// 系列行加锁，保证并发触发时只生成一个实例
+func advanceSeries(seriesID int) (*Task, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var offsets string
+	var task Task
+	err = tx.QueryRow(
+		`SELECT user_id, recurrence, title, description, priority, reminder_offsets
+		FROM task_series WHERE id = ? AND ended_at IS NULL FOR UPDATE`,
+		seriesID,
+	).Scan(&task.UserID, &task.Recurrence, &task.Title, &task.Description, &task.Priority, &offsets)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// 新实例沿用最新实例的项目、标签、创建者、负责人和关注者
+	now := time.Now()
+	from := now
+	var latestID int
+	var latestDue sql.NullTime
+	var latestCompleted bool
+	err = tx.QueryRow(
+		"SELECT id, due_at, completed, project_id, created_by FROM tasks WHERE series_id = ? ORDER BY due_at DESC LIMIT 1",
+		seriesID,
+	).Scan(&latestID, &latestDue, &latestCompleted, &task.ProjectID, &task.CreatedBy)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, err
+	case latestDue.Valid && latestDue.Time.After(now):
+		if !latestCompleted {
+			return nil, nil
+		}
+		from = latestDue.Time
+	}
+
+	due, err := nextOccurrence(task.Recurrence, from)
+	if err != nil {
+		return nil, err
+	}
+	task.DueAt = &due
+	task.SeriesID = &seriesID
+	task.ReminderOffsets = splitOffsets(offsets)
+	if err := resolveTaskStatus(nil, &task); err != nil {
+		return nil, err
+	}
+	if task.Rank, err = nextRank(&task); err != nil {
+		return nil, err
+	}
+	task.StatusChangedAt = &now
+
+	result, err := tx.Exec(
+		`INSERT INTO tasks (title, description, completed, user_id, due_at, priority, project_id,
+			status, board_rank, status_changed_at, created_by, series_id) VALUES (?, ?, FALSE, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.Title, task.Description, task.UserID, task.DueAt, task.Priority, task.ProjectID,
+		task.Status, task.Rank, task.StatusChangedAt, task.CreatedBy, seriesID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range []struct{ table, column string }{
+		{"task_tags", "tag_id"}, {"task_assignees", "user_id"}, {"task_watchers", "user_id"},
+	} {
+		query := "INSERT INTO " + link.table + " (task_id, " + link.column + ") SELECT ?, " + link.column +
+			" FROM " + link.table + " WHERE task_id = ?"
+		if _, err := tx.Exec(query, id, latestID); err != nil {
+			return nil, err
+		}
+	}
+	task.ID = int(id)
+	if err := syncTaskReminders(tx, &task); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	task.Version = 1
+	if err := recordStatusChange(&task, "", nil); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// advanceRecurringTasks 调度器维护作业：为规则已触发、没有待办实例的系列生成下一个实例
+func advanceRecurringTasks() {
+	rows, err := db.Query(
+		`SELECT s.id FROM task_series s WHERE s.ended_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM tasks t WHERE t.series_id = s.id AND t.completed = FALSE AND t.due_at > ?)`,
+		time.Now(),
+	)
+	if err != nil {
+		logger.Errorf("Failed to list recurring task series: %v", err)
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			logger.Errorf("Failed to scan recurring task series: %v", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		task, err := advanceSeries(id)
+		if err != nil {
+			logger.Errorf("Failed to generate next instance of series %d: %v", id, err)
+		} else if task != nil {
+			logger.Infof("Generated task %d for series %d due at %s", task.ID, id, task.DueAt.Format(time.RFC3339))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/pkg/logger"
+)
+
+const slaCheckInterval = time.Minute
+
+// SLAAlert SLA 告警事件的数据
+type SLAAlert struct {
+	TaskID      uint      `json:"task_id"`
+	TaskName    string    `json:"task_name"`
+	ExecutionID uint      `json:"execution_id,omitempty"`
+	Expected    string    `json:"expected"`
+	Actual      string    `json:"actual"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// slaMonitor 定期检查任务的 SLA。已告警的执行和截止时间记录在数据库中，重启后不会重复告警
+type slaMonitor struct {
+	s         *Scheduler
+	lastCheck time.Time
+	mu        sync.Mutex
+}
+
+func newSLAMonitor(s *Scheduler) *slaMonitor {
+	return &slaMonitor{
+		s:         s,
+		lastCheck: time.Now(),
+	}
+}
+
+// check 维护作业入口
+func (m *slaMonitor) check() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	since := m.lastCheck
+	m.lastCheck = now
+
+	tasks, err := m.s.taskRepo.ListAllEnabled()
+	if err != nil {
+		logger.Errorf("SLA check failed to list tasks: %v", err)
+		return
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		if task.MaxDuration > 0 {
+			m.checkDuration(task, since, now)
+		}
+		if deadline, ok := task.SLADeadlineOn(now); ok && now.After(deadline) {
+			m.checkDeadline(task, deadline, now)
+		}
+	}
+}
+
+// checkDuration 检查运行超时的执行，以及上次检查后结束但耗时超限的执行
+func (m *slaMonitor) checkDuration(task *model.Task, since, now time.Time) {
+	limit := time.Duration(task.MaxDuration) * time.Second
+
+	running, err := m.s.statsRepo.ListRunningStartedBefore(task.ID, now.Add(-limit))
+	if err != nil {
+		logger.Warnf("SLA check failed to list running executions of task %d: %v", task.ID, err)
+		return
+	}
+	finished, err := m.s.statsRepo.ListFinishedSince(task.ID, since)
+	if err != nil {
+		logger.Warnf("SLA check failed to list finished executions of task %d: %v", task.ID, err)
+		return
+	}
+
+	for _, e := range append(running, finished...) {
+		end := now
+		if e.EndTime != nil {
+			end = *e.EndTime
+		}
+		elapsed := end.Sub(e.StartTime)
+		if elapsed <= limit {
+			continue
+		}
+
+		claimed, err := m.s.statsRepo.ClaimDurationAlert(e.ID, now)
+		if err != nil {
+			logger.Warnf("SLA check failed to mark execution %d: %v", e.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		m.alert(model.EventSLADurationBreached, SLAAlert{
+			TaskID:      task.ID,
+			TaskName:    task.Name,
+			ExecutionID: e.ID,
+			Expected:    limit.String(),
+			Actual:      elapsed.Truncate(time.Second).String(),
+			DetectedAt:  now,
+		})
+	}
+}
+
+// checkDeadline 检查今天截止时间前是否已有成功执行；截止时间之后才创建或已告警过的不再检查
+func (m *slaMonitor) checkDeadline(task *model.Task, deadline, now time.Time) {
+	if task.CreatedAt.After(deadline) {
+		return
+	}
+	if task.SLAAlertedDeadline != nil && !task.SLAAlertedDeadline.Before(deadline) {
+		return
+	}
+
+	ok, err := m.s.statsRepo.HasSuccessBetween(task.ID, truncateDay(deadline), deadline)
+	if err != nil {
+		logger.Warnf("SLA check failed to query executions of task %d: %v", task.ID, err)
+		return
+	}
+	if ok {
+		return
+	}
+
+	claimed, err := m.s.statsRepo.ClaimDeadlineAlert(task.ID, deadline)
+	if err != nil {
+		logger.Warnf("SLA check failed to mark task %d: %v", task.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	m.alert(model.EventSLADeadlineMissed, SLAAlert{
+		TaskID:     task.ID,
+		TaskName:   task.Name,
+		Expected:   "success by " + task.SLADeadline,
+		Actual:     "no successful execution",
+		DetectedAt: now,
+	})
+}
+
+// alert 发布告警事件，调用方须先在数据库中标记，保证同一违约只告警一次
+func (m *slaMonitor) alert(t model.EventType, data SLAAlert) {
+	logger.Warnf("SLA breached for task %s (ID: %d): expected %s, got %s", data.TaskName, data.TaskID, data.Expected, data.Actual)
+	m.s.webhooks.Publish(t, data)
+}
@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"task-scheduler/internal/model"
+)
+
+// MaxStatsWindow 统计窗口的最大跨度
+const MaxStatsWindow = 366 * 24 * time.Hour
+
+// DailyStats 某一天的执行统计
+type DailyStats struct {
+	Date      string `json:"date"` // YYYY-MM-DD
+	Total     int    `json:"total"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// TaskStats 任务在时间窗口内的可靠性统计
+type TaskStats struct {
+	TaskID               uint         `json:"task_id"`
+	From                 time.Time    `json:"from"`
+	To                   time.Time    `json:"to"`
+	Total                int          `json:"total"`
+	Succeeded            int          `json:"succeeded"`
+	Failed               int          `json:"failed"`
+	Running              int          `json:"running"`
+	SuccessRate          float64      `json:"success_rate"` // 0~1，不含运行中的执行
+	DurationP50Ms        int64        `json:"duration_p50_ms"`
+	DurationP95Ms        int64        `json:"duration_p95_ms"`
+	DurationMaxMs        int64        `json:"duration_max_ms"`
+	CurrentFailureStreak int          `json:"current_failure_streak"`
+	LongestFailureStreak int          `json:"longest_failure_streak"`
+	MTTRSeconds          float64      `json:"mttr_seconds"` // 从首次失败到下一次成功的平均时长
+	Daily                []DailyStats `json:"daily"`
+}
+
+// TaskStats 计算任务在 [from, to) 时间窗口内的统计数据
+func (s *Scheduler) TaskStats(taskID uint, from, to time.Time) (*TaskStats, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > MaxStatsWindow {
+		return nil, fmt.Errorf("window must not exceed %d days", int(MaxStatsWindow.Hours()/24))
+	}
+
+	if _, err := s.taskRepo.GetById(taskID); err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	executions, err := s.statsRepo.ListExecutionsBetween(taskID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list executions: %w", err)
+	}
+
+	return computeTaskStats(taskID, from, to, executions), nil
+}
+
+// computeTaskStats 根据按开始时间升序排列的执行记录计算统计数据
+func computeTaskStats(taskID uint, from, to time.Time, executions []model.TaskExecution) *TaskStats {
+	stats := &TaskStats{TaskID: taskID, From: from, To: to}
+
+	// 预先填充窗口内的每一天，保证直方图连续
+	daily := make(map[string]*DailyStats)
+	for day := truncateDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		stats.Daily = append(stats.Daily, DailyStats{Date: key})
+	}
+	for i := range stats.Daily {
+		daily[stats.Daily[i].Date] = &stats.Daily[i]
+	}
+
+	var durations []int64
+	var streak int
+	var failingSince *time.Time
+	var recoveries []time.Duration
+
+	for _, e := range executions {
+		stats.Total++
+		bucket := daily[e.StartTime.In(from.Location()).Format("2006-01-02")]
+		if bucket != nil {
+			bucket.Total++
+		}
+
+		if e.EndTime != nil && e.Status != model.ExecutionStatusRunning {
+			durations = append(durations, e.EndTime.Sub(e.StartTime).Milliseconds())
+		}
+
+		switch e.Status {
+		case model.ExecutionStatusRunning:
+			stats.Running++
+		case model.ExecutionStatusSuccess:
+			stats.Succeeded++
+			if bucket != nil {
+				bucket.Succeeded++
+			}
+			if failingSince != nil && e.EndTime != nil {
+				recoveries = append(recoveries, e.EndTime.Sub(*failingSince))
+			}
+			failingSince = nil
+			streak = 0
+		case model.ExecutionStatusFailed:
+			stats.Failed++
+			if bucket != nil {
+				bucket.Failed++
+			}
+			if failingSince == nil {
+				failed := e.StartTime
+				failingSince = &failed
+			}
+			streak++
+			if streak > stats.LongestFailureStreak {
+				stats.LongestFailureStreak = streak
+			}
+		}
+	}
+	stats.CurrentFailureStreak = streak
+
+	if finished := stats.Succeeded + stats.Failed; finished > 0 {
+		stats.SuccessRate = float64(stats.Succeeded) / float64(finished)
+	}
+
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		stats.DurationP50Ms = percentile(durations, 50)
+		stats.DurationP95Ms = percentile(durations, 95)
+		stats.DurationMaxMs = durations[len(durations)-1]
+	}
+
+	if len(recoveries) > 0 {
+		var total time.Duration
+		for _, r := range recoveries {
+			total += r
+		}
+		stats.MTTRSeconds = (total / time.Duration(len(recoveries))).Seconds()
+	}
+
+	return stats
+}
+
+// percentile 使用最近秩法计算已排序切片的百分位数
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// truncateDay 返回所在日期的零点
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
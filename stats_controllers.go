@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"task-scheduler/internal/scheduler"
+	"task-scheduler/pkg/logger"
+)
+
+// defaultStatsWindow 未指定 from 时的默认统计窗口
+const defaultStatsWindow = 30 * 24 * time.Hour
+
+// getTaskStatsHandler 获取任务在时间窗口内的统计数据
+func getTaskStatsHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := loadJob(w, r, permReadExecutions)
+	if !ok {
+		return
+	}
+
+	var err error
+	query := r.URL.Query()
+	to := time.Now()
+	if v := query.Get("to"); v != "" {
+		to, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	from := to.Add(-defaultStatsWindow)
+	if v := query.Get("from"); v != "" {
+		from, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if !from.Before(to) {
+		sendErrorResponse(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from) > scheduler.MaxStatsWindow {
+		sendErrorResponse(w, http.StatusBadRequest, "Time window is too large")
+		return
+	}
+
+	stats, err := jobScheduler.TaskStats(task.ID, from, to)
+	if err != nil {
+		logger.Errorf("Error computing task stats: %v", err)
+		sendJobError(w, err, "Failed to compute task stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
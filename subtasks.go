@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"task-scheduler/pkg/validation"
+)
+
+const (
+	// 子任务的最大层级（含顶层任务）
+	maxTaskDepth = 5
+
+	maxChecklistItems      = 100
+	maxChecklistItemLength = 500
+)
+
+// ChecklistItem 任务上的检查项，比子任务更轻量，按列表顺序保存
+type ChecklistItem struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// TaskProgress 由直接子任务和检查项汇总的完成进度
+type TaskProgress struct {
+	Done    int `json:"done"`
+	Total   int `json:"total"`
+	Percent int `json:"percent"`
+}
+
+var errOpenSubtasks = &appError{Kind: errConflict, Code: "open_subtasks", Message: "Task has open subtasks"}
+
+// checkTaskParent 校验父任务存在且属于同一用户，不形成环，且层级不超过 maxTaskDepth
+func checkTaskParent(task *Task) error {
+	if task.ParentID == nil {
+		return nil
+	}
+
+	var v validation.Validator
+	ancestors := 0
+	for id := *task.ParentID; id != 0; ancestors++ {
+		if id == task.ID {
+			v.Add("parent_id", validation.CodeNotAllowed, "must not be the task itself or one of its subtasks")
+			return v.Err()
+		}
+		if ancestors >= maxTaskDepth {
+			break
+		}
+
+		var owner int
+		var parentID sql.NullInt64
+		err := db.QueryRow("SELECT user_id, parent_id FROM tasks WHERE id = ?", id).Scan(&owner, &parentID)
+		if err == sql.ErrNoRows {
+			if ancestors == 0 {
+				v.Add("parent_id", validation.CodeNotAllowed, "must be an existing task")
+				return v.Err()
+			}
+			// 上层任务在遍历期间被删除，链条到此为止
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ancestors == 0 && owner != task.UserID {
+			v.Add("parent_id", validation.CodeNotAllowed, "must be a task of the same owner")
+			return v.Err()
+		}
+		id = int(parentID.Int64)
+	}
+
+	height := 1
+	if task.ID != 0 {
+		var err error
+		if height, err = subtaskHeight(task.ID); err != nil {
+			return err
+		}
+	}
+	v.Check(ancestors+height <= maxTaskDepth, "parent_id", validation.CodeOutOfRange,
+		fmt.Sprintf("subtasks can be nested at most %d levels deep", maxTaskDepth))
+	return v.Err()
+}
+
+// subtaskHeight 返回以任务为根的子任务树的层数，最多数到 maxTaskDepth+1
+func subtaskHeight(id int) (int, error) {
+	height := 0
+	for ids := []int{id}; len(ids) > 0 && height <= maxTaskDepth; height++ {
+		args := make([]interface{}, len(ids))
+		for i, id := range ids {
+			args[i] = id
+		}
+		rows, err := db.Query("SELECT id FROM tasks WHERE parent_id IN ("+placeholders(len(ids))+")", args...)
+		if err != nil {
+			return 0, err
+		}
+		ids = ids[:0]
+		for rows.Next() {
+			var child int
+			if err := rows.Scan(&child); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			ids = append(ids, child)
+		}
+		rows.Close()
+	}
+	return height, nil
+}
+
+// hasOpenSubtasks 判断任务是否有未完成的直接子任务
+func hasOpenSubtasks(id int) (bool, error) {
+	var open bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE parent_id = ? AND completed = FALSE)", id).Scan(&open)
+	return open, err
+}
+
+// syncChecklist 在调用方的事务中将任务的检查项更新为 task.Checklist：保留带已有ID的项，新增ID为0的项，删除其余项
+func syncChecklist(tx *sql.Tx, task *Task) error {
+	if task.Checklist == nil {
+		task.Checklist = []ChecklistItem{}
+	}
+
+	rows, err := tx.Query("SELECT id FROM task_checklist_items WHERE task_id = ?", task.ID)
+	if err != nil {
+		return err
+	}
+	existing := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = true
+	}
+	rows.Close()
+
+	for i := range task.Checklist {
+		item := &task.Checklist[i]
+		if existing[item.ID] {
+			delete(existing, item.ID)
+			if _, err := tx.Exec(
+				"UPDATE task_checklist_items SET text = ?, done = ?, position = ? WHERE id = ?",
+				item.Text, item.Done, i, item.ID,
+			); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO task_checklist_items (task_id, text, done, position) VALUES (?, ?, ?, ?)",
+			task.ID, item.Text, item.Done, i,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+	}
+
+	for id := range existing {
+		if _, err := tx.Exec("DELETE FROM task_checklist_items WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadChecklists 为一组任务填充检查项，并由子任务和检查项计算进度
+func loadChecklists(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	index := make(map[int]*Task, len(tasks))
+	args := make([]interface{}, len(tasks))
+	for i := range tasks {
+		tasks[i].Checklist = []ChecklistItem{}
+		tasks[i].Progress = &TaskProgress{}
+		index[tasks[i].ID] = &tasks[i]
+		args[i] = tasks[i].ID
+	}
+
+	rows, err := db.Query(
+		"SELECT id, task_id, text, done FROM task_checklist_items WHERE task_id IN ("+placeholders(len(tasks))+") ORDER BY position, id",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item ChecklistItem
+		var taskID int
+		if err := rows.Scan(&item.ID, &taskID, &item.Text, &item.Done); err != nil {
+			rows.Close()
+			return err
+		}
+		if task, ok := index[taskID]; ok {
+			task.Checklist = append(task.Checklist, item)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(
+		"SELECT parent_id, COUNT(*), COALESCE(SUM(completed), 0) FROM tasks WHERE parent_id IN ("+placeholders(len(tasks))+") GROUP BY parent_id",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var parentID, total, done int
+		if err := rows.Scan(&parentID, &total, &done); err != nil {
+			return err
+		}
+		if task, ok := index[parentID]; ok {
+			task.Progress.Total, task.Progress.Done = total, done
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		for _, item := range task.Checklist {
+			task.Progress.Total++
+			if item.Done {
+				task.Progress.Done++
+			}
+		}
+		switch {
+		case task.Progress.Total > 0:
+			task.Progress.Percent = task.Progress.Done * 100 / task.Progress.Total
+		case task.Completed:
+			task.Progress.Percent = 100
+		}
+	}
+	return nil
+}
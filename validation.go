@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"task-scheduler/pkg/validation"
+)
+
+// 字段长度上限
+const (
+	minUsernameLength        = 3
+	maxUsernameLength        = 32
+	maxEmailLength           = 255
+	maxTaskTitleLength       = 255
+	maxTaskDescriptionLength = 10000
+	maxRecurrenceLength      = 100
+	maxLabelNameLength       = 100
+)
+
+var (
+	usernamePattern   = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	colorPattern      = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	statusNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Validate 校验用户字段；创建用户时 requirePassword 为 true，更新时密码可省略
+func (u *User) Validate(requirePassword bool) error {
+	var v validation.Validator
+	v.String("username", u.Username, validation.Required,
+		validation.MinLength(minUsernameLength), validation.MaxLength(maxUsernameLength),
+		validation.Matches(usernamePattern, "letters, digits, '_', '.' and '-'"))
+	v.String("email", u.Email, validation.Required, validation.MaxLength(maxEmailLength), validation.Email)
+	if requirePassword {
+		v.String("password", u.Password, validation.Required, validation.Password)
+	} else {
+		v.String("password", u.Password, validation.Password)
+	}
+	v.String("role", u.Role, validation.OneOf(roleAdmin, roleOperator, roleViewer))
+	return v.Err()
+}
+
+// Validate 校验待办任务字段
+func (t *Task) Validate() error {
+	var v validation.Validator
+	v.String("title", t.Title, validation.Required, validation.MaxLength(maxTaskTitleLength))
+	v.String("description", t.Description, validation.MaxLength(maxTaskDescriptionLength))
+	v.Check(t.UserID >= 0, "user_id", validation.CodeOutOfRange, "must not be negative")
+	v.String("priority", t.Priority, validation.OneOf(priorityLow, priorityNormal, priorityHigh, priorityUrgent))
+	v.Check(len(t.ReminderOffsets) <= maxTaskReminders, "reminder_offsets", validation.CodeTooLong,
+		fmt.Sprintf("must contain at most %d reminders", maxTaskReminders))
+	seen := make(map[int]bool)
+	for _, offset := range t.ReminderOffsets {
+		if offset < 0 || offset > maxReminderOffsetMins || seen[offset] {
+			v.Add("reminder_offsets", validation.CodeOutOfRange,
+				fmt.Sprintf("must be distinct minute offsets between 0 and %d", maxReminderOffsetMins))
+			break
+		}
+		seen[offset] = true
+	}
+	if t.Recurrence != "" {
+		v.String("recurrence", t.Recurrence, validation.MaxLength(maxRecurrenceLength), validation.Cron)
+	}
+	v.String("status", t.Status, validation.MaxLength(maxStatusNameLength))
+	v.Check(len(t.Checklist) <= maxChecklistItems, "checklist", validation.CodeTooLong,
+		fmt.Sprintf("must contain at most %d items", maxChecklistItems))
+	for i, item := range t.Checklist {
+		v.String(fmt.Sprintf("checklist[%d].text", i), item.Text,
+			validation.Required, validation.MaxLength(maxChecklistItemLength))
+	}
+	return v.Err()
+}
+
+// Validate 校验项目字段
+func (p *Project) Validate() error {
+	var v validation.Validator
+	v.String("name", p.Name, validation.Required, validation.MaxLength(maxLabelNameLength))
+	v.String("description", p.Description, validation.MaxLength(maxTaskDescriptionLength))
+	if p.Color != "" {
+		v.String("color", p.Color, validation.Matches(colorPattern, "a hex color such as #1e90ff"))
+	}
+	return v.Err()
+}
+
+// Validate 校验标签字段
+func (t *Tag) Validate() error {
+	var v validation.Validator
+	v.String("name", t.Name, validation.Required, validation.MaxLength(maxLabelNameLength))
+	if t.Color != "" {
+		v.String("color", t.Color, validation.Matches(colorPattern, "a hex color such as #1e90ff"))
+	}
+	return v.Err()
+}
+
+// Validate 校验工作流：状态名称唯一、类别合法，至少有一个待办和一个完成状态，转换只引用已有状态
+func (wf *Workflow) Validate() error {
+	var v validation.Validator
+	v.Check(len(wf.Statuses) > 0 && len(wf.Statuses) <= maxWorkflowStatuses, "statuses", validation.CodeOutOfRange,
+		fmt.Sprintf("must contain between 1 and %d statuses", maxWorkflowStatuses))
+	seen := make(map[string]bool)
+	for i, s := range wf.Statuses {
+		field := fmt.Sprintf("statuses[%d]", i)
+		v.String(field+".name", s.Name, validation.Required, validation.MaxLength(maxStatusNameLength),
+			validation.Matches(statusNamePattern, "lowercase letters, digits and '_'"))
+		v.String(field+".category", s.Category, validation.Required,
+			validation.OneOf(statusCategoryTodo, statusCategoryInProgress, statusCategoryDone))
+		v.Check(!seen[s.Name], field+".name", validation.CodeNotAllowed, "must be unique")
+		seen[s.Name] = true
+	}
+	v.Check(wf.firstInCategory(statusCategoryTodo) != "" && wf.firstInCategory(statusCategoryDone) != "",
+		"statuses", validation.CodeRequired, "must contain at least one todo and one done status")
+	for i, t := range wf.Transitions {
+		v.Check(seen[t.From] && seen[t.To], fmt.Sprintf("transitions[%d]", i), validation.CodeNotAllowed,
+			"must reference statuses of the workflow")
+	}
+	return v.Err()
+}
+
+// Validate 校验评论正文
+func (c *Comment) Validate() error {
+	var v validation.Validator
+	v.String("body", c.Body, validation.Required, validation.MaxLength(maxCommentLength))
+	return v.Err()
+}
+
+// validatePassword 单独校验密码强度，用于重置密码
+func validatePassword(password string) error {
+	var v validation.Validator
+	v.String("password", password, validation.Required, validation.Password)
+	return v.Err()
+}
@@ -0,0 +1,487 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	webhookWorkers       = 4
+	webhookQueueSize     = 256
+	webhookRetryBase     = 10 * time.Second
+	webhookRetryMax      = time.Hour
+	webhookRetryBatch    = 100
+	webhookRetryInterval = 30 * time.Second
+	webhookTimeout       = 10 * time.Second
+)
+
+// ErrWebhookTargetNotAllowed webhook 地址指向回环、链路本地或内网地址
+var ErrWebhookTargetNotAllowed = errors.New("webhook target address is not allowed")
+
+// reservedNetworks net.IP 方法之外仍不允许投递的保留网段
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级 NAT
+	"192.0.0.0/24",  // IETF 协议分配
+	"198.18.0.0/15", // 基准测试
+	"64:ff9b::/96",  // NAT64，可映射到内网 IPv4
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// allowPrivateWebhookTargets 本地开发时可通过 WEBHOOK_ALLOW_PRIVATE_TARGETS=true 允许投递到内网地址
+func allowPrivateWebhookTargets() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") == "true"
+}
+
+// isPublicIP 判断地址是否可以作为 webhook 目标
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range reservedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookURL 校验 webhook 地址：必须是绝对的 http(s) 地址，且主机解析出的所有地址都是公网地址
+func ValidateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid webhook url %q", raw)
+	}
+	if allowPrivateWebhookTargets() {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return ErrWebhookTargetNotAllowed
+		}
+	}
+	return nil
+}
+
+// newWebhookClient 创建投递用的 HTTP 客户端。建立连接前再次检查实际连接的地址，
+// 订阅创建后的 DNS 变更和重定向同样不能指向内网
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivateWebhookTargets() {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrWebhookTargetNotAllowed
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: webhookTimeout},
+	}
+}
+
+// WebhookDispatcher 将事件签名后投递给订阅者，失败时按指数退避重试。
+// 事件先写入发件箱（webhook_events），再由 fanOut 分发为投递记录，队列满或进程退出都不会丢失事件
+type WebhookDispatcher struct {
+	db       *gorm.DB
+	subRepo  *repository.WebhookRepository
+	delRepo  *repository.DeliveryRepository
+	evRepo   *repository.EventRepository
+	client   *http.Client
+	notify   chan struct{} // 有新事件提交时唤醒 fanOut
+	queue    chan uint
+	inflight map[uint]struct{}
+	closed   bool // 不再接受新事件
+	drained  bool // 投递队列已关闭
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// NewWebhookDispatcher 创建并启动投递器
+func NewWebhookDispatcher(db *gorm.DB) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		db:       db,
+		subRepo:  repository.NewWebhookRepository(db),
+		delRepo:  repository.NewDeliveryRepository(db),
+		evRepo:   repository.NewEventRepository(db),
+		client:   newWebhookClient(),
+		notify:   make(chan struct{}, 1),
+		queue:    make(chan uint, webhookQueueSize),
+		inflight: make(map[uint]struct{}),
+	}
+
+	d.wg.Add(1)
+	go d.fanOut()
+	for i := 0; i < webhookWorkers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	// 分发上次停止时留在发件箱中的事件
+	d.wake()
+
+	return d
+}
+
+// Publish 发布不属于任何事务的事件（如调度器启停、SLA 告警），写入发件箱后唤醒分发
+func (d *WebhookDispatcher) Publish(t model.EventType, data interface{}) {
+	if err := d.record(d.db, t, data); err != nil {
+		logger.Errorf("Failed to record %s event: %v", t, err)
+		return
+	}
+	d.wake()
+}
+
+// record 将事件写入发件箱；db 为事务时随事务提交或回滚
+func (d *WebhookDispatcher) record(db *gorm.DB, t model.EventType, data interface{}) error {
+	event, err := newEvent(t, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return repository.NewEventRepository(db).Create(&model.WebhookEvent{
+		EventID:   event.ID,
+		EventType: event.Type,
+		Payload:   string(payload),
+	})
+}
+
+// wake 唤醒 fanOut 分发已提交的事件（非阻塞，已有待处理的唤醒时合并）
+func (d *WebhookDispatcher) wake() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Replay 重新投递一条历史记录，返回新的投递记录
+func (d *WebhookDispatcher) Replay(deliveryID uint) (*model.WebhookDelivery, error) {
+	orig, err := d.delRepo.GetById(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+
+	now := time.Now()
+	replay := &model.WebhookDelivery{
+		SubscriptionID: orig.SubscriptionID,
+		EventID:        orig.EventID,
+		EventType:      orig.EventType,
+		Payload:        orig.Payload,
+		Status:         model.DeliveryStatusPending,
+		NextRetryAt:    &now,
+		ReplayOf:       &orig.ID,
+	}
+	replay, err = d.delRepo.Create(replay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delivery record: %w", err)
+	}
+
+	d.enqueue(replay.ID)
+	return replay, nil
+}
+
+// RetryDue 重新排队所有到期的待重试投递，由调度器的维护作业定期调用；
+// 同时唤醒分发，补上在显式事务中产生、提交时没有唤醒的事件
+func (d *WebhookDispatcher) RetryDue() {
+	d.wake()
+
+	deliveries, err := d.delRepo.ListDueForRetry(time.Now(), webhookRetryBatch)
+	if err != nil {
+		logger.Errorf("Failed to list webhook deliveries due for retry: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		d.enqueue(delivery.ID)
+	}
+}
+
+// QueueDepth 返回等待处理的事件和投递数量
+func (d *WebhookDispatcher) QueueDepth() int {
+	pending, err := d.evRepo.CountPending()
+	if err != nil {
+		logger.Warnf("Failed to count pending webhook events: %v", err)
+	}
+	return int(pending) + len(d.queue)
+}
+
+// Stop 停止投递器并等待正在进行的投递完成
+func (d *WebhookDispatcher) Stop() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.notify)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// fanOut 每次被唤醒时分发发件箱中的事件；停止时未分发的事件留在发件箱中，下次启动后分发
+func (d *WebhookDispatcher) fanOut() {
+	defer d.wg.Done()
+	// 事件通道关闭后再关闭投递队列，保证 worker 能处理完剩余任务
+	defer func() {
+		d.mu.Lock()
+		d.drained = true
+		close(d.queue)
+		d.mu.Unlock()
+	}()
+
+	for range d.notify {
+		d.dispatchPending()
+	}
+}
+
+// dispatchPending 按产生顺序分发发件箱中的全部事件
+func (d *WebhookDispatcher) dispatchPending() {
+	for {
+		events, err := d.evRepo.ListPending(webhookRetryBatch)
+		if err != nil {
+			logger.Errorf("Failed to list pending webhook events: %v", err)
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+		subs, err := d.subRepo.ListEnabled()
+		if err != nil {
+			logger.Errorf("Failed to list webhook subscriptions: %v", err)
+			return
+		}
+		for i := range events {
+			if err := d.dispatch(&events[i], subs); err != nil {
+				logger.Errorf("Failed to dispatch %s event %s: %v", events[i].EventType, events[i].EventID, err)
+				return
+			}
+		}
+		if len(events) < webhookRetryBatch {
+			return
+		}
+	}
+}
+
+// dispatch 在一个事务中为每个匹配的订阅创建投递记录并标记事件已分发，提交后放入投递队列
+func (d *WebhookDispatcher) dispatch(event *model.WebhookEvent, subs []model.WebhookSubscription) error {
+	var created []uint
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		created = created[:0]
+		dispatched, err := repository.NewEventRepository(tx).MarkDispatched(event.ID, time.Now())
+		if err != nil || !dispatched {
+			return err
+		}
+
+		delRepo := repository.NewDeliveryRepository(tx)
+		for _, sub := range subs {
+			if !sub.Accepts(event.EventType) {
+				continue
+			}
+			// 预留一个退避周期，避免重试作业与首次投递重复
+			next := time.Now().Add(webhookRetryBase)
+			delivery, err := delRepo.Create(&model.WebhookDelivery{
+				SubscriptionID: sub.ID,
+				EventID:        event.EventID,
+				EventType:      event.EventType,
+				Payload:        event.Payload,
+				Status:         model.DeliveryStatusPending,
+				NextRetryAt:    &next,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create delivery record for subscription %d: %w", sub.ID, err)
+			}
+			created = append(created, delivery.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range created {
+		d.enqueue(id)
+	}
+	return nil
+}
+
+// enqueue 将投递放入队列，已在处理中的投递会被忽略
+func (d *WebhookDispatcher) enqueue(deliveryID uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.drained {
+		return
+	}
+	if _, busy := d.inflight[deliveryID]; busy {
+		return
+	}
+
+	select {
+	case d.queue <- deliveryID:
+		d.inflight[deliveryID] = struct{}{}
+	default:
+		// 队列已满，等待重试作业下次扫描
+	}
+}
+
+func (d *WebhookDispatcher) worker() {
+	defer d.wg.Done()
+	for id := range d.queue {
+		d.deliver(id)
+
+		d.mu.Lock()
+		delete(d.inflight, id)
+		d.mu.Unlock()
+	}
+}
+
+// deliver 执行一次投递尝试并更新投递记录
+func (d *WebhookDispatcher) deliver(deliveryID uint) {
+	delivery, err := d.delRepo.GetById(deliveryID)
+	if err != nil {
+		logger.Errorf("Failed to get delivery %d: %v", deliveryID, err)
+		return
+	}
+	if delivery.Status != model.DeliveryStatusPending {
+		return
+	}
+
+	sub, err := d.subRepo.GetById(delivery.SubscriptionID)
+	if err != nil || !sub.IsEnabled {
+		delivery.Status = model.DeliveryStatusFailed
+		delivery.LastError = "subscription deleted or disabled"
+		delivery.NextRetryAt = nil
+		if _, err := d.delRepo.Update(delivery); err != nil {
+			logger.Errorf("Failed to update delivery %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	delivery.Attempts++
+	code, sendErr := d.send(sub, delivery)
+	delivery.ResponseCode = code
+
+	now := time.Now()
+	if sendErr == nil {
+		delivery.Status = model.DeliveryStatusSuccess
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextRetryAt = nil
+	} else {
+		delivery.LastError = sendErr.Error()
+		maxAttempts := sub.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = 1
+		}
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = model.DeliveryStatusFailed
+			delivery.NextRetryAt = nil
+		} else {
+			next := now.Add(retryBackoff(delivery.Attempts))
+			delivery.NextRetryAt = &next
+		}
+		logger.Warnf("Webhook delivery %d to %s failed (attempt %d): %v", delivery.ID, sub.URL, delivery.Attempts, sendErr)
+	}
+
+	if _, err := d.delRepo.Update(delivery); err != nil {
+		logger.Errorf("Failed to update delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send 发送签名后的请求，返回响应状态码
+func (d *WebhookDispatcher) send(sub *model.WebhookSubscription, delivery *model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-TaskFlow-Event", string(delivery.EventType))
+	req.Header.Set("X-TaskFlow-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-TaskFlow-Timestamp", timestamp)
+	req.Header.Set("X-TaskFlow-Signature", "sha256="+SignPayload(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignPayload 计算 HMAC-SHA256 签名，签名内容为 "时间戳.请求体"
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryBackoff 计算第 attempt 次失败后的等待时间
+func retryBackoff(attempt int) time.Duration {
+	backoff := webhookRetryBase
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return backoff
+}
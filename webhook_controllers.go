@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"task-scheduler/internal/model"
+	"task-scheduler/internal/repository"
+	"task-scheduler/internal/scheduler"
+	"task-scheduler/pkg/logger"
+)
+
+// webhookRequest 创建/更新订阅的请求体
+type webhookRequest struct {
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	EventTypes  []string `json:"event_types"`
+	IsEnabled   *bool    `json:"is_enabled"`
+	MaxAttempts int      `json:"max_attempts"`
+}
+
+// webhookCreatedResponse 创建订阅的响应，签名密钥只在此处返回一次
+type webhookCreatedResponse struct {
+	*model.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// validate 校验请求数据；地址不能指向回环、链路本地或内网地址
+func (req *webhookRequest) validate(ctx context.Context) string {
+	if req.Name == "" || req.URL == "" {
+		return "Name and URL are required"
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL must be an absolute http(s) URL"
+	}
+	if err := scheduler.ValidateWebhookURL(ctx, req.URL); err != nil {
+		if errors.Is(err, scheduler.ErrWebhookTargetNotAllowed) {
+			return "URL must not point to a loopback, link-local or private address"
+		}
+		return "URL host could not be resolved"
+	}
+	for _, et := range req.EventTypes {
+		if !isKnownEventType(model.EventType(et)) {
+			return "Unknown event type: " + et
+		}
+	}
+	if req.MaxAttempts < 0 {
+		return "max_attempts must not be negative"
+	}
+	return ""
+}
+
+func isKnownEventType(t model.EventType) bool {
+	switch t {
+	case model.EventTaskCreated, model.EventTaskUpdated, model.EventTaskDeleted,
+		model.EventTaskStarted, model.EventTaskStopped,
+		model.EventExecutionStarted, model.EventExecutionSucceeded, model.EventExecutionFailed,
+		model.EventSLADurationBreached, model.EventSLADeadlineMissed:
+		return true
+	}
+	return false
+}
+
+// createWebhookHandler 创建webhook订阅
+func createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding webhook: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(r.Context()); msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// 未指定密钥时自动生成
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			logger.Errorf("Error generating webhook secret: %v", err)
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to generate secret")
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	sub := &model.WebhookSubscription{
+		Name:        req.Name,
+		URL:         req.URL,
+		Secret:      secret,
+		EventTypes:  strings.Join(req.EventTypes, ","),
+		IsEnabled:   req.IsEnabled == nil || *req.IsEnabled,
+		MaxAttempts: req.MaxAttempts,
+	}
+	if sub.MaxAttempts == 0 {
+		sub.MaxAttempts = 5
+	}
+
+	sub, err := repository.NewWebhookRepository(jobDB.WithContext(r.Context())).Create(sub)
+	if err != nil {
+		logger.Errorf("Error creating webhook: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+	recordAudit(r, auditActionCreate, auditResourceWebhook, sub.ID, nil, sub)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhookCreatedResponse{WebhookSubscription: sub, Secret: secret})
+}
+
+// getAllWebhooksHandler 获取所有webhook订阅
+func getAllWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	subs, err := repository.NewWebhookRepository(jobDB.WithContext(r.Context())).List()
+	if err != nil {
+		logger.Errorf("Error getting webhooks: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get webhooks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(subs)
+}
+
+// getWebhookHandler 根据ID获取webhook订阅
+func getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	sub, err := repository.NewWebhookRepository(jobDB.WithContext(r.Context())).GetById(uint(id))
+	if err != nil {
+		logger.Errorf("Error getting webhook: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to get webhook")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// updateWebhookHandler 更新webhook订阅
+func updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	repo := repository.NewWebhookRepository(jobDB.WithContext(r.Context()))
+	sub, err := repo.GetById(uint(id))
+	if err != nil {
+		logger.Errorf("Error checking webhook existence: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check webhook existence")
+		}
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("Error decoding webhook update: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := req.validate(r.Context()); msg != "" {
+		sendErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	before := *sub
+	sub.Name = req.Name
+	sub.URL = req.URL
+	sub.EventTypes = strings.Join(req.EventTypes, ",")
+	if req.IsEnabled != nil {
+		sub.IsEnabled = *req.IsEnabled
+	}
+	if req.MaxAttempts > 0 {
+		sub.MaxAttempts = req.MaxAttempts
+	}
+	// 只有显式提供时才轮换密钥
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+
+	sub, err = repo.Update(sub)
+	if err != nil {
+		logger.Errorf("Error updating webhook: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+	recordAudit(r, auditActionUpdate, auditResourceWebhook, sub.ID, &before, sub)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// deleteWebhookHandler 删除webhook订阅
+func deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	repo := repository.NewWebhookRepository(jobDB.WithContext(r.Context()))
+	sub, err := repo.GetById(uint(id))
+	if err != nil {
+		logger.Errorf("Error checking webhook existence: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to check webhook existence")
+		}
+		return
+	}
+
+	if err := repo.Delete(uint(id)); err != nil {
+		logger.Errorf("Error deleting webhook: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	recordAudit(r, auditActionDelete, auditResourceWebhook, sub.ID, sub, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWebhookDeliveriesHandler 获取webhook的投递记录
+func getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 500 {
+			sendErrorResponse(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+	}
+
+	deliveries, err := repository.NewDeliveryRepository(jobDB.WithContext(r.Context())).ListBySubscription(uint(id), limit)
+	if err != nil {
+		logger.Errorf("Error getting webhook deliveries: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get webhook deliveries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// replayWebhookDeliveryHandler 重新投递一条历史记录
+func replayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := jobScheduler.Webhooks().Replay(uint(id))
+	if err != nil {
+		logger.Errorf("Error replaying webhook delivery: %v", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Delivery not found")
+		} else {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to replay delivery")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
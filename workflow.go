@@ -0,0 +1,485 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"task-scheduler/pkg/validation"
+)
+
+// 状态类别：completed 由任务当前状态的类别决定
+const (
+	statusCategoryTodo       = "todo"
+	statusCategoryInProgress = "in_progress"
+	statusCategoryDone       = "done"
+)
+
+const (
+	maxWorkflowStatuses = 20
+	maxStatusNameLength = 50
+
+	// 看板上相邻任务的默认排序间隔
+	rankStep = 1024.0
+	// 相邻排序值的间隔小于该值时重新编排整列，避免反复插入到同一位置后浮点数精度耗尽
+	rankEpsilon = 1e-6
+)
+
+// WorkflowStatus 工作流中的一个状态（看板的一列），按列表顺序展示
+type WorkflowStatus struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// WorkflowTransition 允许的状态转换
+type WorkflowTransition struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Workflow 项目的状态工作流；没有转换规则时允许任意转换
+type Workflow struct {
+	ProjectID   *int                 `json:"project_id"`
+	Default     bool                 `json:"default"` // 项目未配置工作流时使用默认工作流
+	Statuses    []WorkflowStatus     `json:"statuses"`
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+// StatusChange 任务的一次状态变更，ChangedAt 即进入新状态的时间
+type StatusChange struct {
+	ID              int       `json:"id"`
+	TaskID          int       `json:"task_id"`
+	FromStatus      string    `json:"from_status,omitempty"`
+	ToStatus        string    `json:"to_status"`
+	ToCategory      string    `json:"to_category"`
+	ActorID         *int      `json:"actor_id,omitempty"`
+	ChangedAt       time.Time `json:"changed_at"`
+	DurationSeconds int64     `json:"duration_seconds"` // 停留在该状态的时长，当前状态计到现在
+}
+
+// StatusHistory 任务的状态历史及周期时间（首次开始处理到完成）
+type StatusHistory struct {
+	Changes          []StatusChange `json:"changes"`
+	CycleTimeSeconds *int64         `json:"cycle_time_seconds,omitempty"`
+}
+
+// BoardColumn 看板的一列，任务按 rank 排序
+type BoardColumn struct {
+	Status   string `json:"status"`
+	Category string `json:"category"`
+	Total    int    `json:"total"`
+	Tasks    []Task `json:"tasks"`
+}
+
+var (
+	errTransitionNotAllowed = &appError{Kind: errConflict, Code: "transition_not_allowed", Message: "Status transition is not allowed by the workflow"}
+	errStatusInUse          = &appError{Kind: errConflict, Code: "status_in_use", Message: "A removed status is still used by tasks"}
+)
+
+// defaultWorkflow 收件箱和未配置工作流的项目使用的工作流
+func defaultWorkflow(projectID *int) *Workflow {
+	return &Workflow{
+		ProjectID: projectID,
+		Default:   true,
+		Statuses: []WorkflowStatus{
+			{"todo", statusCategoryTodo},
+			{"in_progress", statusCategoryInProgress},
+			{"in_review", statusCategoryInProgress},
+			{"blocked", statusCategoryInProgress},
+			{"done", statusCategoryDone},
+		},
+		Transitions: []WorkflowTransition{},
+	}
+}
+
+// status 按名称查找状态
+func (wf *Workflow) status(name string) (WorkflowStatus, bool) {
+	for _, s := range wf.Statuses {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// firstInCategory 返回类别中的第一个状态
+func (wf *Workflow) firstInCategory(category string) string {
+	for _, s := range wf.Statuses {
+		if s.Category == category {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// allows 判断是否允许从 from 转换到 to
+func (wf *Workflow) allows(from, to string) bool {
+	if from == to || len(wf.Transitions) == 0 {
+		return true
+	}
+	for _, t := range wf.Transitions {
+		if t.From == from && t.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWorkflow 获取项目的工作流，projectID 为空（收件箱）或项目未配置时返回默认工作流
+func GetWorkflow(projectID *int) (*Workflow, error) {
+	if projectID == nil {
+		return defaultWorkflow(nil), nil
+	}
+
+	rows, err := db.Query(
+		"SELECT status, category FROM project_statuses WHERE project_id = ? ORDER BY position", *projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	wf := &Workflow{ProjectID: projectID, Statuses: []WorkflowStatus{}, Transitions: []WorkflowTransition{}}
+	for rows.Next() {
+		var s WorkflowStatus
+		if err := rows.Scan(&s.Name, &s.Category); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		wf.Statuses = append(wf.Statuses, s)
+	}
+	rows.Close()
+	if len(wf.Statuses) == 0 {
+		return defaultWorkflow(projectID), nil
+	}
+
+	rows, err = db.Query(
+		"SELECT from_status, to_status FROM project_status_transitions WHERE project_id = ? ORDER BY from_status, to_status",
+		*projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t WorkflowTransition
+		if err := rows.Scan(&t.From, &t.To); err != nil {
+			return nil, err
+		}
+		wf.Transitions = append(wf.Transitions, t)
+	}
+	return wf, rows.Err()
+}
+
+// SaveWorkflow 替换项目的工作流；仍有任务处于被移除的状态时返回 errStatusInUse
+func SaveWorkflow(projectID int, wf *Workflow) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{projectID}
+	for _, s := range wf.Statuses {
+		args = append(args, s.Name)
+	}
+	var inUse bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM tasks WHERE project_id = ? AND status NOT IN ("+placeholders(len(wf.Statuses))+"))",
+		args...,
+	).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errStatusInUse
+	}
+
+	if _, err := tx.Exec("DELETE FROM project_status_transitions WHERE project_id = ?", projectID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM project_statuses WHERE project_id = ?", projectID); err != nil {
+		return err
+	}
+	for i, s := range wf.Statuses {
+		if _, err := tx.Exec(
+			"INSERT INTO project_statuses (project_id, status, category, position) VALUES (?, ?, ?, ?)",
+			projectID, s.Name, s.Category, i,
+		); err != nil {
+			return err
+		}
+	}
+	for _, t := range wf.Transitions {
+		if _, err := tx.Exec(
+			"INSERT IGNORE INTO project_status_transitions (project_id, from_status, to_status) VALUES (?, ?, ?)",
+			projectID, t.From, t.To,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// resolveTaskStatus 使任务的状态与 completed 保持一致并校验状态转换；existing 为空表示新建任务。
+// 只修改 completed 时取对应类别的第一个状态；未指定状态或任务换到其他项目后原状态不存在时按类别映射
+func resolveTaskStatus(existing, task *Task) error {
+	wf, err := GetWorkflow(task.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	from := ""
+	completedChanged := existing == nil
+	if existing != nil {
+		from = existing.Status
+		completedChanged = task.Completed != existing.Completed
+		if task.Status == "" {
+			task.Status = existing.Status
+		}
+	}
+
+	if _, ok := wf.status(task.Status); !ok {
+		if task.Status != from {
+			return invalidStatus(task.Status)
+		}
+		category := statusCategoryTodo
+		if completedChanged {
+			if task.Completed {
+				category = statusCategoryDone
+			}
+		} else if old, err := GetWorkflow(existing.ProjectID); err != nil {
+			return err
+		} else if s, ok := old.status(existing.Status); ok {
+			category = s.Category
+		}
+		task.Status = wf.firstInCategory(category)
+	} else if task.Status == from && completedChanged {
+		current, _ := wf.status(task.Status)
+		if task.Completed && current.Category != statusCategoryDone {
+			task.Status = wf.firstInCategory(statusCategoryDone)
+		} else if !task.Completed && current.Category == statusCategoryDone {
+			task.Status = wf.firstInCategory(statusCategoryTodo)
+		}
+	}
+
+	current, _ := wf.status(task.Status)
+	task.Completed = current.Category == statusCategoryDone
+	if existing != nil && sameProject(existing.ProjectID, task.ProjectID) && !wf.allows(from, task.Status) {
+		return errTransitionNotAllowed
+	}
+	return nil
+}
+
+// sameProject 判断两个项目ID是否相同，都为空表示同在收件箱
+func sameProject(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// nextRank 返回列底部的排序值
+func nextRank(task *Task) (float64, error) {
+	var rank float64
+	err := db.QueryRow(
+		"SELECT COALESCE(MAX(board_rank), 0) FROM tasks WHERE user_id = ? AND project_id <=> ? AND status = ?",
+		task.UserID, task.ProjectID, task.Status,
+	).Scan(&rank)
+	return rank + rankStep, err
+}
+
+// rankAfter 返回排在 afterID 之后（afterID 为 0 时排在列首）的排序值，间隔过小时先重新编排该列
+func rankAfter(task *Task, afterID int) (float64, error) {
+	rank, ok, err := rankBetween(task, afterID)
+	if err != nil || ok {
+		return rank, err
+	}
+	if err := rebalanceColumn(task); err != nil {
+		return 0, err
+	}
+	rank, _, err = rankBetween(task, afterID)
+	return rank, err
+}
+
+// rebalanceColumn 按当前顺序将任务所在列（不含任务本身）的排序值重新编排为 rankStep 的整数倍。
+// 只改变排序值的存储，不改变顺序，因此不增加版本号
+func rebalanceColumn(task *Task) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"SELECT id FROM tasks WHERE user_id = ? AND project_id <=> ? AND status = ? AND id <> ? ORDER BY board_rank, id FOR UPDATE",
+		task.UserID, task.ProjectID, task.Status, task.ID,
+	)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE tasks SET board_rank = ? WHERE id = ?", float64(i+1)*rankStep, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// rankBetween 计算排在 afterID 之后的排序值；与相邻任务的间隔小于 rankEpsilon 时 ok 为 false
+func rankBetween(task *Task, afterID int) (rank float64, ok bool, err error) {
+	var low sql.NullFloat64
+	if afterID != 0 {
+		err := db.QueryRow(
+			"SELECT board_rank FROM tasks WHERE id = ? AND user_id = ? AND project_id <=> ? AND status = ?",
+			afterID, task.UserID, task.ProjectID, task.Status,
+		).Scan(&low)
+		if err == sql.ErrNoRows {
+			return 0, false, errTaskNotFound
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	var high sql.NullFloat64
+	query := "SELECT MIN(board_rank) FROM tasks WHERE user_id = ? AND project_id <=> ? AND status = ? AND id <> ?"
+	args := []interface{}{task.UserID, task.ProjectID, task.Status, task.ID}
+	if low.Valid {
+		query += " AND board_rank > ?"
+		args = append(args, low.Float64)
+	}
+	if err := db.QueryRow(query, args...).Scan(&high); err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case low.Valid && high.Valid:
+		return (low.Float64 + high.Float64) / 2, high.Float64-low.Float64 >= rankEpsilon, nil
+	case low.Valid:
+		return low.Float64 + rankStep, true, nil
+	case high.Valid:
+		return high.Float64 - rankStep, true, nil
+	}
+	return rankStep, true, nil
+}
+
+// recordStatusChange 记录任务进入新状态
+func recordStatusChange(task *Task, from string, actorID *int) error {
+	category := statusCategoryTodo
+	if wf, err := GetWorkflow(task.ProjectID); err != nil {
+		return err
+	} else if s, ok := wf.status(task.Status); ok {
+		category = s.Category
+	}
+	_, err := db.Exec(
+		"INSERT INTO task_status_history (task_id, from_status, to_status, to_category, actor_id) VALUES (?, ?, ?, ?, ?)",
+		task.ID, from, task.Status, category, actorID,
+	)
+	return err
+}
+
+// GetStatusHistory 获取任务的状态历史，计算每个状态的停留时长和周期时间
+func GetStatusHistory(taskID int) (*StatusHistory, error) {
+	rows, err := db.Query(
+		`SELECT id, task_id, from_status, to_status, to_category, actor_id, changed_at
+		FROM task_status_history WHERE task_id = ? ORDER BY changed_at, id`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &StatusHistory{Changes: []StatusChange{}}
+	for rows.Next() {
+		var c StatusChange
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.FromStatus, &c.ToStatus, &c.ToCategory, &c.ActorID, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		history.Changes = append(history.Changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var started *time.Time
+	for i := range history.Changes {
+		c := &history.Changes[i]
+		end := time.Now()
+		if i+1 < len(history.Changes) {
+			end = history.Changes[i+1].ChangedAt
+		}
+		c.DurationSeconds = int64(end.Sub(c.ChangedAt).Seconds())
+
+		switch {
+		case c.ToCategory == statusCategoryInProgress && started == nil:
+			started = &c.ChangedAt
+		case c.ToCategory == statusCategoryDone && started != nil && history.CycleTimeSeconds == nil:
+			cycle := int64(c.ChangedAt.Sub(*started).Seconds())
+			history.CycleTimeSeconds = &cycle
+		}
+	}
+	return history, nil
+}
+
+// GetBoard 获取用户在项目（为空表示收件箱）中的看板，每列最多返回 limit 个任务
+func GetBoard(userID int, projectID *int, limit int) ([]BoardColumn, error) {
+	wf, err := GetWorkflow(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]BoardColumn, len(wf.Statuses))
+	for i, s := range wf.Statuses {
+		column := BoardColumn{Status: s.Name, Category: s.Category}
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM tasks WHERE user_id = ? AND project_id <=> ? AND status = ?",
+			userID, projectID, s.Name,
+		).Scan(&column.Total)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := db.Query(
+			"SELECT "+taskColumns+" FROM tasks WHERE user_id = ? AND project_id <=> ? AND status = ? ORDER BY board_rank, id LIMIT ?",
+			userID, projectID, s.Name, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		column.Tasks = []Task{}
+		for rows.Next() {
+			task, err := scanTask(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			column.Tasks = append(column.Tasks, *task)
+		}
+		rows.Close()
+		if err := loadTaskDetails(column.Tasks); err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+// invalidStatus 状态不在任务所在项目的工作流中
+func invalidStatus(status string) error {
+	var v validation.Validator
+	v.Add("status", validation.CodeNotAllowed, "must be a status of the project workflow, got "+strconv.Quote(status))
+	return v.Err()
+}
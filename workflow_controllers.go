@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"task-scheduler/pkg/logger"
+)
+
+const (
+	defaultBoardColumnLimit = 100
+	maxBoardColumnLimit     = 500
+)
+
+// moveTaskRequest 看板上移动任务的请求体：移到 status 列中 after_id 之后，after_id 为空时移到列首
+type moveTaskRequest struct {
+	Status  string `json:"status"`
+	AfterID int    `json:"after_id"`
+}
+
+// getProjectWorkflowHandler 获取项目的工作流
+func getProjectWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := loadProject(w, r)
+	if !ok {
+		return
+	}
+
+	wf, err := GetWorkflow(&project.ID)
+	if err != nil {
+		logger.Errorf("Error getting workflow: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get workflow")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wf)
+}
+
+// updateProjectWorkflowHandler 替换项目的工作流，仍有任务使用的状态不能移除
+func updateProjectWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := loadProject(w, r)
+	if !ok {
+		return
+	}
+
+	var wf Workflow
+	if err := json.NewDecoder(r.Body).Decode(&wf); err != nil {
+		logger.Errorf("Error decoding workflow: %v", err)
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := wf.Validate(); err != nil {
+		sendError(w, err, "Invalid request")
+		return
+	}
+
+	before, err := GetWorkflow(&project.ID)
+	if err != nil {
+		logger.Errorf("Error getting workflow: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get workflow")
+		return
+	}
+	if err := SaveWorkflow(project.ID, &wf); err != nil {
+		logger.Errorf("Error saving workflow: %v", err)
+		sendError(w, err, "Failed to save workflow")
+		return
+	}
+	wf.ProjectID = &project.ID
+	wf.Default = false
+	if wf.Transitions == nil {
+		wf.Transitions = []WorkflowTransition{}
+	}
+	recordAudit(r, auditActionUpdate, auditResourceProject, project.ID, before, &wf)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wf)
+}
+
+// parseColumnLimit 解析看板每列返回的任务数
+func parseColumnLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultBoardColumnLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > maxBoardColumnLimit {
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxBoardColumnLimit))
+		return 0, false
+	}
+	return n, true
+}
+
+// writeBoard 查询看板并写入响应
+func writeBoard(w http.ResponseWriter, r *http.Request, userID int, projectID *int) {
+	limit, ok := parseColumnLimit(w, r)
+	if !ok {
+		return
+	}
+
+	columns, err := GetBoard(userID, projectID, limit)
+	if err != nil {
+		logger.Errorf("Error getting board: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get board")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(columns)
+}
+
+// getProjectBoardHandler 获取项目的看板，任务按工作流状态分列
+func getProjectBoardHandler(w http.ResponseWriter, r *http.Request) {
+	project, ok := loadProject(w, r)
+	if !ok {
+		return
+	}
+	writeBoard(w, r, project.UserID, &project.ID)
+}
+
+// getInboxBoardHandler 获取收件箱（不属于任何项目的任务）的看板
+func getInboxBoardHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := labelOwnerFromQuery(w, r)
+	if !ok {
+		return
+	}
+	writeBoard(w, r, userID, nil)
+}
+
+// moveTaskHandler 在看板上移动任务，可同时改变状态；与 PATCH 相同地校验版本和状态转换
+func moveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	modifyTask(w, r, func(existing *Task, body []byte) (*Task, error) {
+		var req moveTaskRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, invalidBody(err)
+		}
+
+		task := *existing
+		if req.Status != "" {
+			task.Status = req.Status
+		}
+		rank, err := rankAfter(&task, req.AfterID)
+		if err != nil {
+			return nil, err
+		}
+		task.Rank = rank
+		return &task, nil
+	})
+}
+
+// getTaskStatusHistoryHandler 获取任务的状态历史和周期时间
+func getTaskStatusHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	task, err := GetTaskByID(r.Context(), id)
+	if err != nil {
+		logger.Errorf("Error checking task existence: %v", err)
+		sendError(w, err, "Failed to check task existence")
+		return
+	}
+	if !canAccessTask(currentUser(r), task) {
+		sendForbidden(w)
+		return
+	}
+
+	history, err := GetStatusHistory(id)
+	if err != nil {
+		logger.Errorf("Error getting status history: %v", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get status history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history)
+}